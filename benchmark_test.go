@@ -3,6 +3,7 @@ package mapx
 import (
 	"sync"
 	"testing"
+	"time"
 )
 
 // Benchmark for RWMutexMap - Read operations
@@ -70,6 +71,31 @@ func BenchmarkRWMutexMap_Set(b *testing.B) {
 	})
 }
 
+// Benchmark for RWMutexMap - Sets that store the value already present
+func BenchmarkRWMutexMap_NoOpSet(b *testing.B) {
+	benchmarkRWMutexMapNoOpSet(b, NewRWMutexMap[int, int]())
+}
+
+// Benchmark for RWMutexMap - Sets that store the value already present, skipped as unchanged
+func BenchmarkRWMutexMap_NoOpSet_SkipUnchanged(b *testing.B) {
+	benchmarkRWMutexMapNoOpSet(b, NewRWMutexMapSkipUnchanged[int, int](nil))
+}
+
+func benchmarkRWMutexMapNoOpSet(b *testing.B, m *RWMutexMap[int, int]) {
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%1000, i%1000)
+			i++
+		}
+	})
+}
+
 // Benchmark for CASMap - Write operations
 func BenchmarkCASMap_Set(b *testing.B) {
 	m := NewCASMap[int, int]()
@@ -301,3 +327,185 @@ func BenchmarkCASMap_GetOrSet(b *testing.B) {
 		}
 	})
 }
+
+// Benchmark for SmallMap - Small map size (10 elements)
+func BenchmarkSmallMap_Small_Get(b *testing.B) {
+	m := NewSmallMap[int, int]()
+	for i := 0; i < 10; i++ {
+		m.Set(i, i*2)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Get(i % 10)
+			i++
+		}
+	})
+}
+
+// Benchmark for CASMap - Small map size (10 elements) write operations
+func BenchmarkCASMap_Small_Set(b *testing.B) {
+	m := NewCASMap[int, int]()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%10, i)
+			i++
+		}
+	})
+}
+
+// Benchmark for SmallMap - Small map size (10 elements) write operations
+func BenchmarkSmallMap_Small_Set(b *testing.B) {
+	m := NewSmallMap[int, int]()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%10, i)
+			i++
+		}
+	})
+}
+
+// Benchmark for CASMap - Large map size (10000 elements) write operations
+func BenchmarkCASMap_Large_Set(b *testing.B) {
+	m := NewCASMapWithCapacity[int, int](10000)
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i*2)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%10000, i)
+			i++
+		}
+	})
+}
+
+// Benchmark for ShardedMap - Large map size (10000 elements) write operations
+func BenchmarkShardedMap_Large_Set(b *testing.B) {
+	m := NewShardedMap[int, int](64)
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i*2)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%10000, i)
+			i++
+		}
+	})
+}
+
+// Benchmark for ShardedMap - Large map size (10000 elements)
+func BenchmarkShardedMap_Large_Get(b *testing.B) {
+	m := NewShardedMap[int, int](64)
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i*2)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Get(i % 10000)
+			i++
+		}
+	})
+}
+
+// Benchmark for CASMap - Batch insert of 500 entries with one Set per entry
+func BenchmarkCASMap_Batch_SetLoop(b *testing.B) {
+	entries := make(map[int]int, 500)
+	for i := 0; i < 500; i++ {
+		entries[i] = i * 2
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := NewCASMap[int, int]()
+		for k, v := range entries {
+			m.Set(k, v)
+		}
+	}
+}
+
+// Benchmark for CASMap - Batch insert of 500 entries with a single Merge
+func BenchmarkCASMap_Batch_Merge(b *testing.B) {
+	entries := make(map[int]int, 500)
+	for i := 0; i < 500; i++ {
+		entries[i] = i * 2
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := NewCASMap[int, int]()
+		m.Merge(entries)
+	}
+}
+
+// Benchmark for CASMap - Writes to a single key under heavy contention, retrying immediately
+func BenchmarkCASMap_Contended_Spin(b *testing.B) {
+	benchmarkCASMapContended(b, NewCASMap[int, int]())
+}
+
+// Benchmark for CASMap - Writes to a single key under heavy contention, yielding after a failed CAS
+func BenchmarkCASMap_Contended_Yield(b *testing.B) {
+	benchmarkCASMapContended(b, NewCASMapWithOptions[int, int](WithYield()))
+}
+
+// Benchmark for CASMap - Writes to a single key under heavy contention, with exponential backoff
+func BenchmarkCASMap_Contended_Backoff(b *testing.B) {
+	benchmarkCASMapContended(b, NewCASMapWithOptions[int, int](WithBackoff(time.Microsecond, 100*time.Microsecond)))
+}
+
+// Benchmark for CASMap - Writes to a single key under heavy contention, falling back to a mutex
+func BenchmarkCASMap_Contended_MaxRetries(b *testing.B) {
+	benchmarkCASMapContended(b, NewCASMapWithOptions[int, int](WithMaxRetries(2)))
+}
+
+// Benchmark for CASMap - Sets to a few keys under heavy contention, so that many CAS attempts fail.
+// Each retry refills the copy made by the failed attempt instead of allocating a new map, so allocs/op
+// stays that of an uncontended Set however high retries/op gets (run with -cpu 4 or more).
+func BenchmarkCASMap_Contended_SetAllocs(b *testing.B) {
+	m := NewCASMap[int, int]()
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	b.ReportAllocs()
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i%4, i)
+			i++
+		}
+	})
+	b.ReportMetric(float64(m.Stats().Retries)/float64(b.N), "retries/op")
+}
+
+func benchmarkCASMapContended(b *testing.B, m *CASMap[int, int]) {
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.Update(0, func(old int, _ bool) int { return old + 1 })
+		}
+	})
+}
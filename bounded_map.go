@@ -0,0 +1,84 @@
+package mapx
+
+// BoundedMap is a concurrent-safe map that holds at most a fixed number of entries and rejects
+// writes that would add a key beyond it, instead of evicting like LRUMap.
+//
+// It is built on CASMap, so reads are lock-free and writes use CAS + Copy-On-Write. The size check
+// is made against the same snapshot the write replaces, so concurrent writers can never push the
+// map past its maximum. Updates to existing keys always succeed.
+type BoundedMap[K comparable, V any] struct {
+	data *CASMap[K, V]
+	max  int
+}
+
+// NewBoundedMap creates a new BoundedMap instance holding at most max entries.
+// A max below 0 is treated as 0, which rejects every new key.
+func NewBoundedMap[K comparable, V any](max int) *BoundedMap[K, V] {
+	if max < 0 {
+		max = 0
+	}
+	return &BoundedMap[K, V]{
+		data: NewCASMap[K, V](),
+		max:  max,
+	}
+}
+
+// Cap returns the maximum number of key-value pairs the map holds.
+func (m *BoundedMap[K, V]) Cap() int {
+	return m.max
+}
+
+// Get retrieves the value associated with the given key.
+// Returns the zero value and false if the key doesn't exist; otherwise returns the value and true.
+func (m *BoundedMap[K, V]) Get(key K) (V, bool) {
+	return m.data.Get(key)
+}
+
+// Set associates the given value with the given key.
+// Returns false without storing anything if the key doesn't exist and the map is full.
+func (m *BoundedMap[K, V]) Set(key K, value V) bool {
+	return m.data.setIf(key, value, func(data map[K]V, exists bool) bool {
+		return exists || len(data) < m.max
+	})
+}
+
+// SetIfAbsent sets the value for the given key only if it doesn't already exist and the map isn't full.
+// Returns true if the value was set, false if the key already existed or the map is full.
+func (m *BoundedMap[K, V]) SetIfAbsent(key K, value V) bool {
+	return m.data.setIf(key, value, func(data map[K]V, exists bool) bool {
+		return !exists && len(data) < m.max
+	})
+}
+
+// Delete removes the given key from the map, making room for another one.
+// Has no effect if the key doesn't exist.
+func (m *BoundedMap[K, V]) Delete(key K) {
+	m.data.Delete(key)
+}
+
+// Len returns the number of key-value pairs in the map.
+func (m *BoundedMap[K, V]) Len() int {
+	return m.data.Len()
+}
+
+// Has checks whether the given key exists in the map.
+func (m *BoundedMap[K, V]) Has(key K) bool {
+	return m.data.Has(key)
+}
+
+// Clear removes all key-value pairs from the map.
+func (m *BoundedMap[K, V]) Clear() {
+	m.data.Clear()
+}
+
+// Range iterates over all key-value pairs in the map.
+// Calls f for each pair, stopping iteration if f returns false.
+// Iteration is over a snapshot, so it's safe to call write methods within f.
+func (m *BoundedMap[K, V]) Range(f func(key K, value V) bool) {
+	m.data.Range(f)
+}
+
+// Keys returns a slice containing all keys in the map.
+func (m *BoundedMap[K, V]) Keys() []K {
+	return m.data.Keys()
+}
@@ -0,0 +1,86 @@
+package mapx
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBoundedMap_BasicOperations(t *testing.T) {
+	m := NewBoundedMap[string, int](2)
+	if m.Cap() != 2 {
+		t.Errorf("Expected capacity 2, got %d", m.Cap())
+	}
+
+	if !m.Set("key1", 100) || !m.Set("key2", 200) {
+		t.Fatal("Expected Sets below capacity to succeed")
+	}
+	if m.Set("key3", 300) {
+		t.Error("Expected Set of a new key to be rejected at capacity")
+	}
+	if m.SetIfAbsent("key3", 300) {
+		t.Error("Expected SetIfAbsent of a new key to be rejected at capacity")
+	}
+	if m.Has("key3") || m.Len() != 2 {
+		t.Errorf("Expected rejected key not to be stored, got %v", m.Keys())
+	}
+
+	// Updates to existing keys always succeed
+	if !m.Set("key1", 101) {
+		t.Error("Expected update of an existing key to succeed at capacity")
+	}
+	if val, ok := m.Get("key1"); !ok || val != 101 {
+		t.Errorf("Expected (101, true), got (%d, %v)", val, ok)
+	}
+	if m.SetIfAbsent("key1", 102) {
+		t.Error("Expected SetIfAbsent of an existing key to fail")
+	}
+
+	// Deleting makes room again
+	m.Delete("key2")
+	if !m.Set("key3", 300) {
+		t.Error("Expected Set to succeed after Delete made room")
+	}
+	m.Clear()
+	if m.Len() != 0 || !m.SetIfAbsent("key4", 400) {
+		t.Error("Expected SetIfAbsent to succeed after Clear")
+	}
+}
+
+func TestBoundedMap_ZeroCapacity(t *testing.T) {
+	m := NewBoundedMap[string, int](-1)
+	if m.Cap() != 0 || m.Set("key1", 100) {
+		t.Error("Expected a negative max to reject every new key")
+	}
+}
+
+func TestBoundedMap_Concurrent(t *testing.T) {
+	const max, goroutines, perGoroutine = 50, 10, 20
+	m := NewBoundedMap[int, int](max)
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				key := g*perGoroutine + i
+				if m.Set(key, key) {
+					accepted.Add(1)
+				}
+				if n := m.Len(); n > max {
+					t.Errorf("Map grew past its maximum: %d entries", n)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := accepted.Load(); n != max {
+		t.Errorf("Expected exactly %d accepted Sets, got %d", max, n)
+	}
+	if m.Len() != max {
+		t.Errorf("Expected length %d, got %d", max, m.Len())
+	}
+}
@@ -1,7 +1,16 @@
 package mapx
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
 	"sync/atomic"
+	"time"
 )
 
 // CASMap is a concurrent-safe Map implementation based on CAS (Compare-And-Swap) + Copy-On-Write,
@@ -21,7 +30,16 @@ import (
 //   - Not suitable for large maps or write-heavy scenarios
 //   - Under high write concurrency, CAS may fail and retry, degrading performance
 type CASMap[K comparable, V any] struct {
-	data atomic.Pointer[map[K]V]
+	data  atomic.Pointer[map[K]V]
+	eq    func(a, b V) bool // optional equality for conditional operations
+	clone func(v V) V       // optional copy applied to values returned by reads
+
+	contention *casContention // retry settings; nil unless created with NewCASMapWithOptions
+	stats      casStats       // write and retry counts reported by Stats
+
+	observers observers[K, V] // callbacks registered with OnChange
+	onWrite   writeHook       // callback registered with OnWrite
+	loads     loadGroup[K, V] // loads in flight for GetOrLoad
 }
 
 // NewCASMap creates a new CASMap instance.
@@ -33,7 +51,8 @@ func NewCASMap[K comparable, V any]() *CASMap[K, V] {
 }
 
 // NewCASMapWithCapacity creates a new CASMap instance with pre-allocated capacity.
-// Pre-allocating capacity can reduce performance overhead from map growth.
+// Since every write copies the map into a new one sized for the current length, the capacity only
+// benefits the first write; to insert a known number of entries at once, use Merge, which copies once.
 func NewCASMapWithCapacity[K comparable, V any](capacity int) *CASMap[K, V] {
 	m := &CASMap[K, V]{}
 	newMap := make(map[K]V, capacity)
@@ -41,6 +60,60 @@ func NewCASMapWithCapacity[K comparable, V any](capacity int) *CASMap[K, V] {
 	return m
 }
 
+// NewCASMapWithOptions creates a new CASMap instance whose writes retry failed CAS attempts as
+// configured by opts instead of immediately. See WithMaxRetries, WithYield and WithBackoff.
+func NewCASMapWithOptions[K comparable, V any](opts ...CASOption) *CASMap[K, V] {
+	m := NewCASMap[K, V]()
+	m.contention = &casContention{}
+	for _, opt := range opts {
+		opt(&m.contention.opts)
+	}
+	return m
+}
+
+// NewCASMapWithClone creates a new CASMap instance whose reads return clone(v) instead of the stored
+// value v. For pointer, slice or map values this keeps callers from mutating the shared snapshot
+// through a value they read. Every read-only method that returns or yields values of the map returns
+// clones, as does Freeze; write methods that also return a value, such as Swap, GetOrSet or
+// GetAndDelete, don't, and neither do callbacks such as predicates or comparators, which receive the
+// stored values. Values passed to writes are stored as is, so callers must not mutate them afterwards.
+func NewCASMapWithClone[K comparable, V any](clone func(v V) V) *CASMap[K, V] {
+	m := NewCASMap[K, V]()
+	m.clone = clone
+	return m
+}
+
+// NewCASMapFromMap creates a new CASMap instance holding a copy of src.
+// The map is built with a single copy instead of one per Set, and src is not retained,
+// so it may be modified afterwards without affecting the new map.
+// The copy isn't sized with headroom for later inserts: the first write copies the map into one sized
+// for its current length, discarding any reserve. To add a known burst of entries, use Merge, which copies once.
+func NewCASMapFromMap[K comparable, V any](src map[K]V) *CASMap[K, V] {
+	m := &CASMap[K, V]{}
+	newMap := m.copyMap(src)
+	m.data.Store(&newMap)
+	return m
+}
+
+// CollectCASMap creates a new CASMap instance holding the key-value pairs of seq, like maps.Collect.
+// The pairs are collected into a single map that becomes the first snapshot, without a copy per pair.
+// If seq yields a key more than once, the last value wins.
+func CollectCASMap[K comparable, V any](seq iter.Seq2[K, V]) *CASMap[K, V] {
+	m := &CASMap[K, V]{}
+	newMap := maps.Collect(seq)
+	m.data.Store(&newMap)
+	return m
+}
+
+// NewCASMapWithEqual creates a new CASMap instance that uses eq to compare values
+// in CompareAndSwap and CompareAndDelete instead of the default comparison.
+// This makes the conditional operations usable for values where == is wrong or panics.
+func NewCASMapWithEqual[K comparable, V any](eq func(a, b V) bool) *CASMap[K, V] {
+	m := NewCASMap[K, V]()
+	m.eq = eq
+	return m
+}
+
 // load atomically loads the current map pointer.
 func (m *CASMap[K, V]) load() map[K]V {
 	return *m.data.Load()
@@ -52,22 +125,113 @@ func (m *CASMap[K, V]) load() map[K]V {
 func (m *CASMap[K, V]) Get(key K) (V, bool) {
 	data := m.load()
 	value, ok := data[key]
+	if ok && m.clone != nil {
+		value = m.clone(value)
+	}
 	return value, ok
 }
 
+// GetOr returns the value associated with the given key, or def if the key doesn't exist.
+// Unlike GetOrSet, it never modifies the map.
+func (m *CASMap[K, V]) GetOr(key K, def V) V {
+	if value, ok := m.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// GetMulti returns a newly allocated plain map with the entries for the given keys that exist.
+// All values are read from a single snapshot, so the result is a consistent view of the requested
+// keys at one point in time, which separate Get calls can't guarantee. Missing keys are omitted.
+func (m *CASMap[K, V]) GetMulti(keys ...K) map[K]V {
+	data := m.load()
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if v, ok := data[key]; ok {
+			result[key] = m.cloneValue(v)
+		}
+	}
+	return result
+}
+
 // Set associates the given value with the given key.
 // If the key already exists, the old value will be overwritten.
 // Uses Copy-On-Write + CAS strategy with automatic retry on failure.
 func (m *CASMap[K, V]) Set(key K, value V) {
+	c := m.observers.begin()
+	defer c.flush()
+	t := m.onWrite.begin()
+	defer t.report("Set")
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
 	for {
 		oldPtr := m.data.Load()
 		oldMap := *oldPtr
-		newMap := m.copyMap(oldMap)
+		old, existed := oldMap[key]
+		newMap = m.recopy(newMap, oldMap)
 		newMap[key] = value
 		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			t.store(len(oldMap))
+			c.set(key, old, existed, value)
 			return
 		}
 		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// SetContext is like Set, but gives up and returns ctx.Err() if ctx is done before a CAS attempt succeeds.
+// This bounds the time spent retrying under heavy write contention. If it returns an error, the value
+// was not stored.
+func (m *CASMap[K, V]) SetContext(ctx context.Context, key K, value V) error {
+	c := m.observers.begin()
+	defer c.flush()
+	t := m.onWrite.begin()
+	defer t.report("Set")
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		old, existed := oldMap[key]
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = value
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			t.store(len(oldMap))
+			c.set(key, old, existed, value)
+			return nil
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// Swap stores the value for the given key and returns the previous value, if any.
+// The loaded result reports whether the key was present, mirroring sync.Map.Swap, so it tells
+// in the same atomic operation whether the call created the key (false) or updated it (true).
+func (m *CASMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		previous, loaded = oldMap[key]
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = value
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.set(key, previous, loaded, value)
+			return previous, loaded
+		}
+		// CAS failed, retry
+		r.backoff()
 	}
 }
 
@@ -75,23 +239,306 @@ func (m *CASMap[K, V]) Set(key K, value V) {
 // Has no effect if the key doesn't exist.
 // Uses Copy-On-Write + CAS strategy with automatic retry on failure.
 func (m *CASMap[K, V]) Delete(key K) {
+	c := m.observers.begin()
+	defer c.flush()
+	t := m.onWrite.begin()
+	defer t.report("Delete")
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
 	for {
 		oldPtr := m.data.Load()
 		oldMap := *oldPtr
 		// Return early if key doesn't exist
-		if _, ok := oldMap[key]; !ok {
+		old, ok := oldMap[key]
+		if !ok {
 			return
 		}
-		newMap := m.copyMap(oldMap)
+		newMap = m.recopy(newMap, oldMap)
 		delete(newMap, key)
 		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			t.store(len(oldMap))
+			c.delete(key, old)
 			return
 		}
 		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// DeleteContext is like Delete, but gives up and returns ctx.Err() if ctx is done before a CAS attempt
+// succeeds. If it returns an error, the key was not removed.
+func (m *CASMap[K, V]) DeleteContext(ctx context.Context, key K) error {
+	c := m.observers.begin()
+	defer c.flush()
+	t := m.onWrite.begin()
+	defer t.report("Delete")
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		old, ok := oldMap[key]
+		if !ok {
+			return nil
+		}
+		newMap = m.recopy(newMap, oldMap)
+		delete(newMap, key)
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			t.store(len(oldMap))
+			c.delete(key, old)
+			return nil
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// GetAndDelete removes the given key and returns its previous value.
+// Returns the value and true if the key existed; otherwise returns the zero value and false without copying.
+// The read and delete happen inside the same CAS attempt, so two callers never get the same value.
+func (m *CASMap[K, V]) GetAndDelete(key K) (V, bool) {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		v, ok := oldMap[key]
+		if !ok {
+			return v, false
+		}
+		newMap = m.recopy(newMap, oldMap)
+		delete(newMap, key)
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.delete(key, v)
+			return v, true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// Pop removes an arbitrary entry and returns it.
+// Returns the key, value and true if the map wasn't empty; otherwise returns the zero values and false.
+// The entry is chosen in map iteration order. Selection and removal happen inside the same CAS attempt,
+// so two callers never pop the same entry.
+func (m *CASMap[K, V]) Pop() (key K, value V, ok bool) {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		if len(oldMap) == 0 {
+			return key, value, false
+		}
+		for key, value = range oldMap {
+			break
+		}
+		newMap = m.recopy(newMap, oldMap)
+		delete(newMap, key)
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.delete(key, value)
+			return key, value, true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// Rename atomically moves the value of from to the key to, overwriting any value stored under to.
+// Returns false without changing the map if from doesn't exist. Deleting from and setting to happen
+// in a single copy-on-write update, so readers never see both keys or neither. Renaming a key to
+// itself returns whether it exists and changes nothing.
+func (m *CASMap[K, V]) Rename(from, to K) bool {
+	return m.rename(from, to, true)
+}
+
+// RenameIfAbsent is like Rename, but only moves the value if to doesn't exist.
+// Returns false without changing the map if from doesn't exist or to already exists.
+func (m *CASMap[K, V]) RenameIfAbsent(from, to K) bool {
+	return m.rename(from, to, false)
+}
+
+// rename moves the value of from to to, replacing an existing value under to only if overwrite is set.
+func (m *CASMap[K, V]) rename(from, to K, overwrite bool) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		v, ok := oldMap[from]
+		if !ok {
+			return false
+		}
+		old, exists := oldMap[to]
+		if exists && !overwrite {
+			return false
+		}
+		if from == to {
+			return true
+		}
+		newMap = m.recopy(newMap, oldMap)
+		delete(newMap, from)
+		newMap[to] = v
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.delete(from, v)
+			c.set(to, old, exists, v)
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// SwapKeys atomically exchanges the values of keys a and b in a single copy-on-write update, so
+// readers observe either both old values or both new ones. Returns false without changing the map
+// if either key doesn't exist. Swapping a key with itself returns whether it exists and changes nothing.
+func (m *CASMap[K, V]) SwapKeys(a, b K) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		va, okA := oldMap[a]
+		vb, okB := oldMap[b]
+		if !okA || !okB {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		newMap = m.recopy(newMap, oldMap)
+		newMap[a], newMap[b] = vb, va
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.set(a, va, true, vb)
+			c.set(b, vb, true, va)
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// DeleteMulti removes all given keys in a single copy-on-write update and returns the number of
+// keys actually removed. Returns 0 without copying if none of the keys exist.
+// If fewer than a quarter of the entries remain, they are copied once more into a map sized for them,
+// so that the snapshot doesn't keep the storage of the larger map; see Compact.
+// Uses Copy-On-Write + CAS strategy with automatic retry on failure.
+func (m *CASMap[K, V]) DeleteMulti(keys ...K) int {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		// Return early if no key exists to avoid unnecessary copy
+		if !containsAnyKey(oldMap, keys) {
+			return 0
+		}
+		newMap = m.recopy(newMap, oldMap)
+		removed := 0
+		for _, key := range keys {
+			if old, ok := newMap[key]; ok {
+				delete(newMap, key)
+				c.delete(key, old)
+				removed++
+			}
+		}
+		newMap = shrunk(newMap, len(oldMap))
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			return removed
+		}
+		// CAS failed, retry
+		r.backoff()
+		c.discard()
+	}
+}
+
+// Batch calls f to buffer writes in a Txn and then applies all of them in a single copy-on-write
+// update, so readers observe either none or all of the batch. f runs once, before anything is
+// applied, so reads made inside f, including through the map's own methods, see the pre-batch state.
+// If the CAS fails, the buffered writes are replayed on the new contents without calling f again.
+func (m *CASMap[K, V]) Batch(f func(tx *Txn[K, V])) {
+	var tx Txn[K, V]
+	f(&tx)
+	if len(tx.ops) == 0 {
+		return
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	r := m.beginWrite()
+	defer r.release()
+	var newMap map[K]V
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		newMap = m.recopy(newMap, oldMap)
+		tx.apply(newMap, &c)
+		newMap = shrunk(newMap, len(oldMap))
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			return
+		}
+		// CAS failed, retry
+		r.backoff()
+		c.discard()
+	}
+}
+
+// DeleteWhere removes all entries for which pred returns true in a single copy-on-write update
+// and returns the number of entries removed. Returns 0 without copying if nothing matches.
+// Like DeleteMulti, it stores a map sized for the remaining entries if fewer than a quarter remain.
+// The scan and copy run inside the CAS retry loop, so pred may be called more than once per entry
+// and must be free of side effects.
+func (m *CASMap[K, V]) DeleteWhere(pred func(key K, value V) bool) int {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		matched := matchingKeys(oldMap, pred)
+		// Return early if nothing matches to avoid unnecessary copy
+		if len(matched) == 0 {
+			return 0
+		}
+		newMap = m.recopy(newMap, oldMap)
+		for _, key := range matched {
+			delete(newMap, key)
+		}
+		newMap = shrunk(newMap, len(oldMap))
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			for _, key := range matched {
+				c.delete(key, oldMap[key])
+			}
+			return len(matched)
+		}
+		// CAS failed, retry
+		r.backoff()
 	}
 }
 
 // Len returns the number of key-value pairs in the map.
+// It is O(1): the snapshot is loaded atomically and Go maps track their own length.
 func (m *CASMap[K, V]) Len() int {
 	data := m.load()
 	return len(data)
@@ -105,23 +552,186 @@ func (m *CASMap[K, V]) Has(key K) bool {
 }
 
 // Clear removes all key-value pairs from the map.
+// The empty map is not pre-sized: the next write would copy it into a map sized for its length anyway,
+// so to refill a cleared map with a known batch, use Merge, which copies once.
 func (m *CASMap[K, V]) Clear() {
+	c := m.observers.begin()
+	defer c.flush()
 	newMap := make(map[K]V)
 	m.data.Store(&newMap)
+	c.clear()
+}
+
+// Compact rebuilds the map into a copy sized for its current length and atomically installs it.
+// Go maps never shrink, so after a bulk removal such as DeleteWhere or DeleteMulti the snapshot keeps the
+// backing storage of its former size until the next write copies it; Compact reclaims it right away.
+// Bulk removals that leave fewer than a quarter of the entries already store a copy sized for them.
+// It is O(n) and leaves the contents unchanged, so no change events are emitted.
+func (m *CASMap[K, V]) Compact() {
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		// A copy from a failed attempt may be sized for more entries, so always copy afresh
+		newMap := m.copyMap(*oldPtr)
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			return
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// Reload replaces the contents of the map with the map returned by provider in one atomic operation.
+// If provider returns an error the map is left unchanged and the error is returned.
+// Readers observe either the old or the new contents, never a partially loaded or empty map.
+// The returned map is copied, so the provider may keep using it afterwards.
+func (m *CASMap[K, V]) Reload(provider func() (map[K]V, error)) error {
+	data, err := provider()
+	if err != nil {
+		return err
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	newMap := m.copyMap(data)
+	m.data.Store(&newMap)
+	c.replace(newMap)
+	return nil
+}
+
+// Replace atomically replaces the whole contents of the map with a copy of newData and returns
+// the previous contents. Readers observe either the old or the new contents, never a partially
+// populated or empty map. Both maps are copied, because snapshots are shared with readers and clones:
+// newData may be modified afterwards, and the caller owns the returned map.
+func (m *CASMap[K, V]) Replace(newData map[K]V) (old map[K]V) {
+	newMap := m.copyMap(newData)
+	c := m.observers.begin()
+	defer c.flush()
+	oldPtr := m.data.Swap(&newMap)
+	c.replace(newMap)
+	return m.copyMap(*oldPtr)
+}
+
+// SwapMap atomically installs newData as the contents of the map without copying it and returns
+// the previous snapshot, also without copying. It is a zero-copy alternative to Replace for callers
+// that build the next contents themselves. The map takes ownership of newData: the caller must not
+// retain or modify it after the call, since readers access it without synchronization. The returned
+// map may still be in use by readers, clones and frozen views, so it must be treated as read-only.
+// A nil newData installs an empty map.
+func (m *CASMap[K, V]) SwapMap(newData map[K]V) (old map[K]V) {
+	if newData == nil {
+		newData = make(map[K]V)
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	oldPtr := m.data.Swap(&newData)
+	c.replace(newData)
+	return *oldPtr
+}
+
+// Drain atomically empties the map and returns its previous contents, so that every entry is
+// returned by exactly one Drain and no read after Drain observes a drained entry.
+// It is like Replace with an empty map. The returned map is a copy, because the drained snapshot may
+// still be shared with readers and clones, so the caller owns it.
+func (m *CASMap[K, V]) Drain() map[K]V {
+	c := m.observers.begin()
+	defer c.flush()
+	newMap := make(map[K]V)
+	oldPtr := m.data.Swap(&newMap)
+	c.clear()
+	return m.copyMap(*oldPtr)
+}
+
+// Merge sets all entries of other in a single copy-on-write update, overwriting existing keys.
+// Each attempt copies the whole map once regardless of the number of entries and publishes it
+// with a single CAS, so readers observe either none or all of the merged entries.
+// other is not retained and may be modified afterwards.
+//
+// Merge is the batch form of Set: since every Set copies the whole map, inserting N keys with
+// separate Sets costs O(n²), while a single Merge costs O(n).
+func (m *CASMap[K, V]) Merge(other map[K]V) {
+	m.MergeFunc(other, nil)
+}
+
+// MergeFunc is like Merge, but calls resolve for keys present in both maps and stores its result.
+// resolve receives the current value and the value from other. A nil resolve keeps the value from other.
+// The copy-modify-store runs inside the CAS retry loop, so resolve may be called more than once
+// per key and must be free of side effects.
+func (m *CASMap[K, V]) MergeFunc(other map[K]V, resolve func(key K, oldValue, newValue V) V) {
+	// Return early if there's nothing to merge to avoid unnecessary copy
+	if len(other) == 0 {
+		return
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	t := m.onWrite.begin()
+	defer t.report("Merge")
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		if newMap == nil {
+			newMap = m.copyMapWithCapacity(oldMap, len(oldMap)+len(other))
+		} else {
+			newMap = m.recopy(newMap, oldMap)
+		}
+		for k, v := range other {
+			if old, ok := oldMap[k]; ok && resolve != nil {
+				v = resolve(k, old, v)
+			}
+			newMap[k] = v
+		}
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			t.store(len(oldMap))
+			for k := range other {
+				old, ok := oldMap[k]
+				c.set(k, old, ok, newMap[k])
+			}
+			return
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
 }
 
 // Range iterates over all key-value pairs in the map.
 // Calls f for each pair, stopping iteration if f returns false.
-// Note: iteration is over a snapshot; concurrent writes don't affect the current iteration.
+// Note: iteration is over a snapshot; concurrent writes don't affect the current iteration,
+// so it's safe to call write methods within f without deadlock.
+// The snapshot is the published map itself rather than a copy, so Range doesn't allocate.
 func (m *CASMap[K, V]) Range(f func(key K, value V) bool) {
 	data := m.load()
 	for k, v := range data {
-		if !f(k, v) {
+		if !f(k, m.cloneValue(v)) {
 			break
 		}
 	}
 }
 
+// RangeIndexed is like Range, but also passes f a zero-based counter that increases by one per pair,
+// for example to assign sequential IDs during a scan. The counter only reflects the position in this
+// iteration: map order is unspecified, so the same key may get a different index on every call.
+func (m *CASMap[K, V]) RangeIndexed(f func(i int, key K, value V) bool) {
+	i := 0
+	for k, v := range m.load() {
+		if !f(i, k, m.cloneValue(v)) {
+			break
+		}
+		i++
+	}
+}
+
+// All returns an iterator over all key-value pairs in the map, for use with range-over-func.
+// Like Range, it iterates over the snapshot taken when iteration starts, so concurrent writes
+// don't affect what is yielded, and it stops when the loop body breaks.
+func (m *CASMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(yield)
+	}
+}
+
 // Keys returns a slice containing all keys in the map.
 func (m *CASMap[K, V]) Keys() []K {
 	data := m.load()
@@ -137,21 +747,315 @@ func (m *CASMap[K, V]) Values() []V {
 	data := m.load()
 	values := make([]V, 0, len(data))
 	for _, v := range data {
-		values = append(values, v)
+		values = append(values, m.cloneValue(v))
 	}
 	return values
 }
 
+// Items returns the keys and values of the map as two slices built from a single snapshot,
+// so that values[i] is the value of keys[i]. Calling Keys and Values separately may observe
+// two different snapshots, and their orders don't correspond.
+func (m *CASMap[K, V]) Items() (keys []K, values []V) {
+	data := m.load()
+	keys = make([]K, 0, len(data))
+	values = make([]V, 0, len(data))
+	for k, v := range data {
+		keys = append(keys, k)
+		values = append(values, m.cloneValue(v))
+	}
+	return keys, values
+}
+
+// Entries returns the key-value pairs of a snapshot as a newly allocated slice in unspecified order.
+// The caller owns the returned slice; modifying it doesn't affect the map. See SortedEntries for
+// entries in key order.
+func (m *CASMap[K, V]) Entries() []Entry[K, V] {
+	data := m.load()
+	entries := make([]Entry[K, V], 0, len(data))
+	for k, v := range data {
+		entries = append(entries, Entry[K, V]{Key: k, Value: m.cloneValue(v)})
+	}
+	return entries
+}
+
+// Update atomically replaces the value for the given key with the result of f and returns the new value.
+// f receives the current value (or the zero value) and whether the key exists.
+// The whole copy-modify-store runs inside the CAS retry loop, so f may be called more than once
+// and must be free of side effects.
+func (m *CASMap[K, V]) Update(key K, f func(old V, exists bool) V) V {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		v, ok := oldMap[key]
+		newValue := f(v, ok)
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = newValue
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.set(key, v, ok, newValue)
+			return newValue
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// UpdateMulti atomically replaces the values of keys with the results of f, storing all of them in
+// a single copy-on-write update so readers observe either none or all of the new values.
+// f receives each key with its current value (or the zero value) and whether it exists.
+// keys must not contain duplicates; each key is passed its value from before the update.
+// The copy-modify-store runs inside the CAS retry loop, so f may be called more than once per key
+// and must be free of side effects.
+func (m *CASMap[K, V]) UpdateMulti(keys []K, f func(key K, old V, exists bool) V) {
+	m.compute(keys, func(key K, value V, exists bool) (V, bool) {
+		return f(key, value, exists), true
+	})
+}
+
+// Snapshot returns a newly allocated plain map with the current contents.
+// The caller owns the returned map and may modify it freely.
+// It is O(n) in the size of the map and allocates a full copy.
+func (m *CASMap[K, V]) Snapshot() map[K]V {
+	snapshot := m.copyMap(m.load())
+	if m.clone != nil {
+		for k, v := range snapshot {
+			snapshot[k] = m.clone(v)
+		}
+	}
+	return snapshot
+}
+
+// CopyInto copies a snapshot of the map into dst, overwriting keys that already exist in dst and
+// leaving its other keys untouched. Unlike Snapshot, it doesn't allocate a new map, so the caller
+// controls allocation and can accumulate the contents of several maps. dst must not be nil.
+func (m *CASMap[K, V]) CopyInto(dst map[K]V) {
+	for k, v := range m.load() {
+		dst[k] = m.cloneValue(v)
+	}
+}
+
+// Filter returns a newly allocated plain map with the entries for which pred returns true.
+// It scans a snapshot without locking and doesn't modify the map; the caller owns the returned map.
+func (m *CASMap[K, V]) Filter(pred func(key K, value V) bool) map[K]V {
+	result := make(map[K]V)
+	for k, v := range m.load() {
+		if pred(k, v) {
+			result[k] = m.cloneValue(v)
+		}
+	}
+	return result
+}
+
+// Partition splits a snapshot into two newly allocated plain maps in a single pass: matched holds
+// the entries for which pred returns true and rest holds the others. Together they contain every
+// entry exactly once, which is cheaper than calling Filter twice with opposite predicates.
+func (m *CASMap[K, V]) Partition(pred func(key K, value V) bool) (matched, rest map[K]V) {
+	matched = make(map[K]V)
+	rest = make(map[K]V)
+	for k, v := range m.load() {
+		if pred(k, v) {
+			matched[k] = m.cloneValue(v)
+		} else {
+			rest[k] = m.cloneValue(v)
+		}
+	}
+	return matched, rest
+}
+
+// KeysWhere returns the keys of the entries for which pred returns true, scanning a single snapshot.
+// Unlike filtering the result of Keys, it only allocates for the matching keys.
+// The order of keys is unspecified.
+func (m *CASMap[K, V]) KeysWhere(pred func(key K, value V) bool) []K {
+	var keys []K
+	for k, v := range m.load() {
+		if pred(k, v) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// CountWhere returns the number of entries for which pred returns true, scanning a single snapshot.
+// Unlike Filter or KeysWhere, it doesn't allocate.
+func (m *CASMap[K, V]) CountWhere(pred func(key K, value V) bool) int {
+	n := 0
+	for k, v := range m.load() {
+		if pred(k, v) {
+			n++
+		}
+	}
+	return n
+}
+
+// Diff compares the current contents against old and classifies each key: added keys exist only in
+// the map, removed keys exist only in old, and changed keys exist in both with values that differ
+// according to eq. A nil eq uses the map's equality function (see CompareAndSwap).
+// It scans a snapshot without locking; the order of keys in each slice is unspecified.
+func (m *CASMap[K, V]) Diff(old map[K]V, eq func(a, b V) bool) (added, removed, changed []K) {
+	if eq == nil {
+		eq = m.equal
+	}
+	data := m.load()
+	for k, v := range data {
+		oldValue, ok := old[k]
+		if !ok {
+			added = append(added, k)
+		} else if !eq(oldValue, v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := data[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	return added, removed, changed
+}
+
+// Clone returns a new map with the same contents in O(1) time.
+// The clone shares the current immutable snapshot with the original; since every write copies
+// the snapshot before modifying it, the first write to either map materializes its own copy and
+// the two maps are fully independent afterwards. Callbacks registered with OnChange are not cloned.
+func (m *CASMap[K, V]) Clone() *CASMap[K, V] {
+	c := &CASMap[K, V]{eq: m.eq, clone: m.clone}
+	if m.contention != nil {
+		c.contention = &casContention{opts: m.contention.opts}
+	}
+	c.data.Store(m.data.Load())
+	return c
+}
+
+// Freeze returns an immutable view of the current contents in O(1) time.
+// The view shares the current snapshot, which is never modified because every write copies it,
+// so later writes to the map don't affect the view. Unlike Snapshot, nothing is copied.
+func (m *CASMap[K, V]) Freeze() ReadOnlyMap[K, V] {
+	return ReadOnlyMap[K, V]{data: m.load(), clone: m.clone}
+}
+
+// CompareAndDelete atomically deletes the given key only if its current value equals oldValue.
+// Returns true if the key was deleted, false if it doesn't exist or its value doesn't match.
+// Values are compared the same way as in CompareAndSwap.
+func (m *CASMap[K, V]) CompareAndDelete(key K, oldValue V) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		v, ok := oldMap[key]
+		if !ok || !m.equal(v, oldValue) {
+			return false
+		}
+		newMap = m.recopy(newMap, oldMap)
+		delete(newMap, key)
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.delete(key, v)
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// KeysSeq returns an iterator over all keys in the map.
+// Unlike Keys, it yields lazily from a snapshot without building an intermediate slice.
+func (m *CASMap[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		data := m.load()
+		for k := range data {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesSeq returns an iterator over all values in the map.
+// Unlike Values, it yields lazily from a snapshot without building an intermediate slice.
+func (m *CASMap[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		data := m.load()
+		for _, v := range data {
+			if !yield(m.cloneValue(v)) {
+				return
+			}
+		}
+	}
+}
+
+// ContainsValue reports whether any key maps to a value equal to value according to eq.
+// A nil eq uses the map's equality function (see CompareAndSwap), which also handles non-comparable
+// values. It scans a snapshot without locking and stops at the first match.
+func (m *CASMap[K, V]) ContainsValue(value V, eq func(a, b V) bool) bool {
+	if eq == nil {
+		eq = m.equal
+	}
+	for _, v := range m.load() {
+		if eq(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxEntry returns the key-value pair with the largest value according to less,
+// or ok=false if the map is empty. If several values are equally large, any of them may be returned.
+// The result is computed from a single consistent snapshot.
+func (m *CASMap[K, V]) MaxEntry(less func(a, b V) bool) (key K, value V, ok bool) {
+	data := m.load()
+	for k, v := range data {
+		if !ok || less(value, v) {
+			key, value, ok = k, v, true
+		}
+	}
+	if ok {
+		value = m.cloneValue(value)
+	}
+	return key, value, ok
+}
+
+// MinEntry returns the key-value pair with the smallest value according to less,
+// or ok=false if the map is empty. If several values are equally small, any of them may be returned.
+// The result is computed from a single consistent snapshot.
+func (m *CASMap[K, V]) MinEntry(less func(a, b V) bool) (key K, value V, ok bool) {
+	data := m.load()
+	for k, v := range data {
+		if !ok || less(v, value) {
+			key, value, ok = k, v, true
+		}
+	}
+	if ok {
+		value = m.cloneValue(value)
+	}
+	return key, value, ok
+}
+
 // GetOrSet retrieves the value for the given key, or sets it to the given value if it doesn't exist.
 // Returns the value and true if the key already existed; otherwise returns the new value and false.
+//
+// The result is linearizable: it takes effect at the atomic load of the snapshot it was decided on
+// (or at the successful CAS when the value is set). A value returned with true was present in the map
+// at that point, so a concurrent Delete of the same key is simply ordered after the GetOrSet.
 func (m *CASMap[K, V]) GetOrSet(key K, value V) (V, bool) {
-	// Fast path: check if key exists
+	// Fast path: check if key exists. The loaded snapshot is the current state at the time of the load,
+	// so returning from it is consistent even if the key is deleted right afterwards.
 	data := m.load()
 	if v, ok := data[key]; ok {
 		return v, true
 	}
 
 	// Key doesn't exist, use CAS to set
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
 	for {
 		oldPtr := m.data.Load()
 		oldMap := *oldPtr
@@ -159,56 +1063,513 @@ func (m *CASMap[K, V]) GetOrSet(key K, value V) (V, bool) {
 		if v, ok := oldMap[key]; ok {
 			return v, true
 		}
-		newMap := m.copyMap(oldMap)
+		newMap = m.recopy(newMap, oldMap)
 		newMap[key] = value
 		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			var zero V
+			c.set(key, zero, false, value)
 			return value, false
 		}
 		// CAS failed, retry
+		r.backoff()
 	}
 }
 
 // SetIfAbsent sets the value for the given key only if it doesn't already exist.
 // Returns true if the value was set, false if the key already existed.
 func (m *CASMap[K, V]) SetIfAbsent(key K, value V) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
 	for {
 		oldPtr := m.data.Load()
 		oldMap := *oldPtr
 		if _, ok := oldMap[key]; ok {
 			return false
 		}
-		newMap := m.copyMap(oldMap)
+		newMap = m.recopy(newMap, oldMap)
 		newMap[key] = value
 		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			var zero V
+			c.set(key, zero, false, value)
 			return true
 		}
 		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// SetIfPresent sets the value for the given key only if it already exists; it never inserts the key.
+// Returns true if the value was set, false without copying if the key doesn't exist.
+func (m *CASMap[K, V]) SetIfPresent(key K, value V) bool {
+	return m.setIf(key, value, func(_ map[K]V, exists bool) bool {
+		return exists
+	})
+}
+
+// GetOrCompute retrieves the value for the given key, or sets it to the result of f if it doesn't exist.
+// Returns the value and true if the key already existed; otherwise returns the computed value and false.
+//
+// f is only called when the key is absent, and at most once per call: the computed value is reused
+// when the CAS fails and has to be retried. If a concurrent writer sets the key between computing the
+// value and the CAS, the computed value is discarded and the existing value is returned with true,
+// so f may run without its result being stored. Concurrent calls for the same absent key may each run f.
+func (m *CASMap[K, V]) GetOrCompute(key K, f func() V) (V, bool) {
+	// Fast path: check if key exists
+	data := m.load()
+	if v, ok := data[key]; ok {
+		return v, true
+	}
+
+	c := m.observers.begin()
+	defer c.flush()
+	var value V
+	computed := false
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		// Double-check before computing so f isn't called if the key was set in the meantime
+		if v, ok := oldMap[key]; ok {
+			return v, true
+		}
+		if !computed {
+			value = f()
+			computed = true
+		}
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = value
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			var zero V
+			c.set(key, zero, false, value)
+			return value, false
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// GetOrSetFunc is like GetOrCompute for initializers that can fail: f is only called when the key is
+// absent, and its value is stored only if it returns a nil error. On error nothing is stored and the
+// error is returned with the zero value and false.
+//
+// As in GetOrCompute, f runs at most once per call and its value is reused when the CAS is retried.
+// If a concurrent writer sets the key after f returns, the existing value is returned with true
+// instead of running f again.
+func (m *CASMap[K, V]) GetOrSetFunc(key K, f func() (V, error)) (V, bool, error) {
+	// Fast path: check if key exists
+	data := m.load()
+	if v, ok := data[key]; ok {
+		return v, true, nil
+	}
+
+	c := m.observers.begin()
+	defer c.flush()
+	var value V
+	computed := false
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		// Double-check before calling f so it isn't called if the key was set in the meantime
+		if v, ok := oldMap[key]; ok {
+			return v, true, nil
+		}
+		if !computed {
+			var err error
+			if value, err = f(); err != nil {
+				var zero V
+				return zero, false, err
+			}
+			computed = true
+		}
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = value
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			var zero V
+			c.set(key, zero, false, value)
+			return value, false, nil
+		}
+		// CAS failed, retry
+		r.backoff()
 	}
 }
 
+// GetOrLoad retrieves the value for the given key, or loads it with loader and stores it if it doesn't exist.
+// Concurrent calls missing the same key share a single loader call and all receive its result.
+// If loader returns an error, nothing is stored and the error is returned to every caller waiting
+// on that call; the next call retries. If the key is set concurrently while loading, the stored value
+// wins and is returned instead of the loaded one, as in GetOrSet.
+func (m *CASMap[K, V]) GetOrLoad(key K, loader func(key K) (V, error)) (V, error) {
+	return getOrLoad[K, V](m, &m.loads, key, loader)
+}
+
 // CompareAndSwap atomically compares and swaps: sets newValue only if current value equals oldValue.
 // Returns true if the swap succeeded, false if it failed (key doesn't exist or value doesn't match).
+// Values are compared with the map's equality function if one was supplied; otherwise values of
+// non-comparable types such as slices are compared with reflect.DeepEqual.
 func (m *CASMap[K, V]) CompareAndSwap(key K, oldValue, newValue V) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
 	for {
 		oldPtr := m.data.Load()
 		oldMap := *oldPtr
 		v, ok := oldMap[key]
-		if !ok || !compare(v, oldValue) {
+		if !ok || !m.equal(v, oldValue) {
 			return false
 		}
-		newMap := m.copyMap(oldMap)
+		newMap = m.recopy(newMap, oldMap)
 		newMap[key] = newValue
 		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.set(key, v, true, newValue)
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// CompareAndSwapFunc atomically stores the value returned by shouldSwap for the given key if it
+// also returns true. shouldSwap receives the current value (or the zero value) and whether the key
+// exists, which allows conditions other than equality, such as only moving a version forward.
+// Returns true if the value was stored.
+// The load-decide-store runs inside the CAS retry loop, so shouldSwap may be called more than once
+// and must be free of side effects.
+func (m *CASMap[K, V]) CompareAndSwapFunc(key K, shouldSwap func(current V, exists bool) (V, bool)) bool {
+	return m.compute([]K{key}, func(_ K, value V, exists bool) (V, bool) {
+		return shouldSwap(value, exists)
+	})
+}
+
+// ReplaceIf sets newValue for the given key only if the key exists and cond returns true for its
+// current value. It returns the current value, whether or not it was replaced, and whether the
+// replacement happened; for a missing key it returns the zero value and false.
+// Unlike CompareAndSwap, the condition can be any predicate, and the previous value is returned.
+// cond runs inside the CAS retry loop, so it may be called more than once and must be free of side effects.
+func (m *CASMap[K, V]) ReplaceIf(key K, newValue V, cond func(current V) bool) (previous V, replaced bool) {
+	replaced = m.compute([]K{key}, func(_ K, value V, exists bool) (V, bool) {
+		previous = value
+		return newValue, exists && cond(value)
+	})
+	return previous, replaced
+}
+
+// Load is Get under the name used by sync.Map, to ease migrating from it.
+func (m *CASMap[K, V]) Load(key K) (value V, ok bool) {
+	return m.Get(key)
+}
+
+// Store is Set under the name used by sync.Map, to ease migrating from it.
+func (m *CASMap[K, V]) Store(key K, value V) {
+	m.Set(key, value)
+}
+
+// LoadOrStore is GetOrSet under the name used by sync.Map, to ease migrating from it.
+// Returns the existing value and true if the key was present; otherwise stores value and returns it and false.
+func (m *CASMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	return m.GetOrSet(key, value)
+}
+
+// LoadAndDelete is GetAndDelete under the name used by sync.Map, to ease migrating from it.
+func (m *CASMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	return m.GetAndDelete(key)
+}
+
+// OnChange registers f to be called for every change made to the map and returns a function that
+// unregisters it. Several callbacks may be registered; each change is delivered to all of them in
+// registration order, and a write that changes several keys reports one event per key.
+//
+// Callbacks run synchronously on the writer's goroutine after the new snapshot has been stored and
+// without holding any lock, so they may call methods of the map. They delay the return of the write,
+// so they must be fast. Events of concurrent writes may be delivered concurrently and in a different
+// order than the writes took effect.
+func (m *CASMap[K, V]) OnChange(f func(event ChangeEvent[K, V])) (unregister func()) {
+	return m.observers.add(f)
+}
+
+// Subscribe returns a channel that receives every change made to the map, for consuming events on
+// another goroutine instead of in an OnChange callback, and a function that stops delivery and closes
+// the channel. Events are sent in the same way as OnChange callbacks are called, after the new
+// snapshot has been stored, but never block the writer: an event that doesn't fit into the channel's
+// buffer of the given size is dropped, so the buffer must be large enough for the consumer to keep up.
+func (m *CASMap[K, V]) Subscribe(buffer int) (<-chan ChangeEvent[K, V], func()) {
+	return m.observers.subscribe(buffer)
+}
+
+// MapStats reports how often the writes of a CASMap had to retry.
+type MapStats struct {
+	Writes  int64 // write operations that go through the CAS loop, including ones with nothing to change
+	Retries int64 // failed CAS attempts that had to be retried
+}
+
+// Stats returns the number of write operations and failed CAS attempts since the map was created.
+// Retries divided by Writes is the average number of extra copies each write costs under contention;
+// if it stays high, RWMutexMap, whose writes never retry, is likely the better choice.
+// Writes that replace the whole contents at once (Clear, Reload, Replace and the decoders) never
+// retry and are not counted. Clones start counting from zero.
+func (m *CASMap[K, V]) Stats() MapStats {
+	return MapStats{
+		Writes:  m.stats.writes.Load(),
+		Retries: m.stats.retries.Load(),
+	}
+}
+
+// OnWrite registers f to be called after every Set, Delete and Merge that copies the map, with the
+// method name ("Set", "Delete" or "Merge"; SetContext, DeleteContext and MergeFunc report as the
+// method they extend), the number of entries in the copied map, and the time from the start of the
+// call until the copy was stored, which includes CAS retries. This exposes the O(n) cost of
+// copy-on-write as the map grows. Writes that don't copy, such as deleting a missing key, are not reported.
+// f runs on the writing goroutine after the write completes. Registering replaces the previous
+// callback, and a nil f removes it; without a callback, writes don't read the clock.
+func (m *CASMap[K, V]) OnWrite(f func(op string, copiedEntries int, duration time.Duration)) {
+	m.onWrite.set(f)
+}
+
+// WriteMetrics writes the map's metrics to w in the Prometheus text exposition format,
+// with each metric name prefixed by prefix and an underscore: the gauge <prefix>_entries holding the
+// number of entries, and the counters <prefix>_writes_total and <prefix>_cas_retries_total holding
+// the Writes and Retries reported by Stats.
+func (m *CASMap[K, V]) WriteMetrics(w io.Writer, prefix string) error {
+	stats := m.Stats()
+	if err := writeMetric(w, metricName(prefix, "entries"), "Number of entries in the map.", "gauge", int64(m.Len())); err != nil {
+		return err
+	}
+	if err := writeMetric(w, metricName(prefix, "writes_total"), "Write operations that went through the CAS loop.", "counter", stats.Writes); err != nil {
+		return err
+	}
+	return writeMetric(w, metricName(prefix, "cas_retries_total"), "Failed CAS attempts that had to be retried.", "counter", stats.Retries)
+}
+
+// String implements fmt.Stringer, formatting a snapshot of the map like a plain map, for example
+// "map[a:1 b:2]", so that printing the map with %v shows its contents instead of its internals.
+// The output is deterministic: fmt sorts the keys, ordering keys of non-ordered types by a fixed rule.
+func (m *CASMap[K, V]) String() string {
+	return fmt.Sprint(m.load())
+}
+
+// MarshalJSON implements json.Marshaler, encoding a snapshot of the map as a JSON object.
+// Keys must be strings, integers, or implement encoding.TextMarshaler, as for a plain Go map;
+// other key types make MarshalJSON return an error.
+func (m *CASMap[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.load())
+}
+
+// WriteJSON streams a snapshot of the map to w as a JSON object, writing the same bytes as MarshalJSON.
+// Unlike MarshalJSON, it doesn't build the whole document in memory: entries are encoded and written
+// one at a time, so only the keys and one encoded value are held at once. Keys follow the same rules
+// as for MarshalJSON; an unsupported key type is reported before anything is written.
+func (m *CASMap[K, V]) WriteJSON(w io.Writer) error {
+	return writeJSON(w, m.load())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding a JSON object and atomically replacing
+// the contents of the map with it. The map is left unchanged if decoding fails.
+func (m *CASMap[K, V]) UnmarshalJSON(data []byte) error {
+	var newMap map[K]V
+	if err := json.Unmarshal(data, &newMap); err != nil {
+		return err
+	}
+	if newMap == nil {
+		newMap = make(map[K]V)
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	m.data.Store(&newMap)
+	c.replace(newMap)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, encoding a snapshot of the key-value pairs.
+func (m *CASMap[K, V]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m.load()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, decoding the key-value pairs and atomically replacing
+// the contents of the map with them. The map is left unchanged if decoding fails.
+func (m *CASMap[K, V]) GobDecode(data []byte) error {
+	var newMap map[K]V
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&newMap); err != nil {
+		return err
+	}
+	if newMap == nil {
+		newMap = make(map[K]V)
+	}
+	c := m.observers.begin()
+	defer c.flush()
+	m.data.Store(&newMap)
+	c.replace(newMap)
+	return nil
+}
+
+// compute atomically replaces the values of keys with the ones returned by f.
+// f is called again with the latest values whenever the CAS fails, so it may run more than once per key.
+func (m *CASMap[K, V]) compute(keys []K, f func(key K, value V, exists bool) (V, bool)) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var stored []K
+	var buf map[K]V // copy made by the last failed attempt, reused by the next one
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		var newMap map[K]V
+		stored = stored[:0]
+		for _, key := range keys {
+			v, ok := oldMap[key]
+			newValue, store := f(key, v, ok)
+			if !store {
+				continue
+			}
+			if newMap == nil {
+				newMap = m.recopy(buf, oldMap)
+			}
+			newMap[key] = newValue
+			if c.active() {
+				stored = append(stored, key)
+			}
+		}
+		if newMap == nil {
+			return false
+		}
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			for _, key := range stored {
+				old, ok := oldMap[key]
+				c.set(key, old, ok, newMap[key])
+			}
 			return true
 		}
 		// CAS failed, retry
+		buf = newMap
+		r.backoff()
 	}
 }
 
+// update atomically applies the op returned by f to key.
+// f is called again with the latest value whenever the CAS fails, so it may run more than once.
+func (m *CASMap[K, V]) update(key K, f func(value V, exists bool) (V, updateOp)) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		v, ok := oldMap[key]
+		newValue, op := f(v, ok)
+		if op == updateKeep || (op == updateDelete && !ok) {
+			return false
+		}
+		newMap = m.recopy(newMap, oldMap)
+		if op == updateDelete {
+			delete(newMap, key)
+		} else {
+			newMap[key] = newValue
+		}
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			if op == updateDelete {
+				c.delete(key, v)
+			} else {
+				c.set(key, v, ok, newValue)
+			}
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// beginWrite counts a write operation and starts tracking its failed CAS attempts.
+// The caller must call release on the result when the operation is done.
+func (m *CASMap[K, V]) beginWrite() casRetry {
+	m.stats.writes.Add(1)
+	r := m.contention.begin()
+	r.retries = &m.stats.retries
+	return r
+}
+
+// setIf sets the value for the given key only if cond, called with the current contents and whether
+// the key exists, returns true. Returns whether the value was set. cond runs inside the CAS retry loop,
+// so it may be called more than once and must not modify data.
+func (m *CASMap[K, V]) setIf(key K, value V, cond func(data map[K]V, exists bool) bool) bool {
+	c := m.observers.begin()
+	defer c.flush()
+	var newMap map[K]V
+	r := m.beginWrite()
+	defer r.release()
+	for {
+		oldPtr := m.data.Load()
+		oldMap := *oldPtr
+		old, existed := oldMap[key]
+		if !cond(oldMap, existed) {
+			return false
+		}
+		newMap = m.recopy(newMap, oldMap)
+		newMap[key] = value
+		if m.data.CompareAndSwap(oldPtr, &newMap) {
+			c.set(key, old, existed, value)
+			return true
+		}
+		// CAS failed, retry
+		r.backoff()
+	}
+}
+
+// cloneValue returns v copied with the map's clone function, or v itself if there is none.
+func (m *CASMap[K, V]) cloneValue(v V) V {
+	if m.clone != nil {
+		return m.clone(v)
+	}
+	return v
+}
+
+// equal compares two values with the map's equality function, falling back to compare.
+func (m *CASMap[K, V]) equal(a, b V) bool {
+	if m.eq != nil {
+		return m.eq(a, b)
+	}
+	return compare(a, b)
+}
+
 // copyMap creates a shallow copy of the map with all key-value pairs.
 // This is the core implementation of the Copy-On-Write strategy.
 func (m *CASMap[K, V]) copyMap(oldMap map[K]V) map[K]V {
-	newMap := make(map[K]V, len(oldMap))
+	return m.copyMapWithCapacity(oldMap, len(oldMap))
+}
+
+// recopy is like copyMap, but reuses buf, the copy made by a failed CAS attempt, if there is one.
+// A copy whose CAS failed was never published, so no reader can hold it and it is safe to clear
+// and refill it instead of allocating a new map for the retry. Every write loop that copies the map
+// reuses its failed copy this way, except Compact, whose copy must be sized for the current length.
+func (m *CASMap[K, V]) recopy(buf, oldMap map[K]V) map[K]V {
+	if buf == nil {
+		return m.copyMap(oldMap)
+	}
+	clear(buf)
+	for k, v := range oldMap {
+		buf[k] = v
+	}
+	return buf
+}
+
+// copyMapWithCapacity is like copyMap, but sizes the new map for capacity entries
+// so that adding entries to the copy doesn't grow it again.
+func (m *CASMap[K, V]) copyMapWithCapacity(oldMap map[K]V, capacity int) map[K]V {
+	newMap := make(map[K]V, capacity)
 	for k, v := range oldMap {
 		newMap[k] = v
 	}
@@ -0,0 +1,130 @@
+package mapx
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CASOption configures how a CASMap created with NewCASMapWithOptions retries failed CAS attempts.
+type CASOption func(*casOptions)
+
+// casOptions holds the retry settings of a CASMap.
+type casOptions struct {
+	maxRetries  int           // failed attempts before falling back to the mutex; 0 means never
+	yield       bool          // call runtime.Gosched after a failed attempt
+	backoffBase time.Duration // first sleep after a failed attempt; 0 disables sleeping
+	backoffMax  time.Duration // upper bound for the doubled sleep
+}
+
+// WithMaxRetries makes a write that fails its CAS n times fall back to a mutex shared by all writers
+// of the map. While any write is in the fallback path, new writes take the mutex before their first
+// attempt, so the writer holding it only competes with writes that were already retrying and is not
+// starved indefinitely. A non-positive n disables the fallback.
+//
+// As with RWMutexMap, callbacks passed to write methods (such as Update or GetOrCompute) must not
+// write to the same map, since they may run while the fallback mutex is held.
+func WithMaxRetries(n int) CASOption {
+	return func(o *casOptions) {
+		o.maxRetries = n
+	}
+}
+
+// WithYield makes a write call runtime.Gosched after each failed CAS attempt instead of retrying
+// immediately, giving the goroutine that won a chance to finish. It is ignored if WithBackoff is set.
+func WithYield() CASOption {
+	return func(o *casOptions) {
+		o.yield = true
+	}
+}
+
+// WithBackoff makes a write sleep after each failed CAS attempt, starting at base and doubling
+// after every further failure up to max. A max below base, including 0, is raised to base, so the
+// sleep stays at base instead of doubling; there is no uncapped mode, as doubling without a bound
+// would soon sleep for hours. A non-positive base disables sleeping.
+func WithBackoff(base, max time.Duration) CASOption {
+	return func(o *casOptions) {
+		o.backoffBase = base
+		o.backoffMax = max
+		if max < base {
+			o.backoffMax = base
+		}
+	}
+}
+
+// casContention holds the retry settings of a CASMap along with the state of its mutex fallback.
+type casContention struct {
+	opts     casOptions
+	mu       sync.Mutex   // serializes writes in the fallback path
+	fallback atomic.Int32 // number of writes in or waiting for the fallback path
+}
+
+// casStats counts the write operations of a CASMap and their failed CAS attempts.
+type casStats struct {
+	writes  atomic.Int64
+	retries atomic.Int64
+}
+
+// casRetry tracks the failed attempts of a single write operation.
+// With a nil cont, as for maps without options, it retries immediately and never falls back.
+type casRetry struct {
+	cont     *casContention
+	retries  *atomic.Int64 // counts failed attempts if non-nil
+	attempts int
+	locked   bool
+}
+
+// begin starts tracking a write operation, taking the fallback mutex first if another write holds
+// or is waiting for it. The caller must call release when the operation is done.
+func (c *casContention) begin() casRetry {
+	r := casRetry{cont: c}
+	if c != nil && c.fallback.Load() > 0 {
+		r.lock()
+	}
+	return r
+}
+
+// lock enters the fallback path.
+func (r *casRetry) lock() {
+	r.cont.fallback.Add(1)
+	r.cont.mu.Lock()
+	r.locked = true
+}
+
+// backoff is called after a failed CAS attempt, before the next one. It enters the fallback path once
+// the retry limit is reached and then waits as configured.
+func (r *casRetry) backoff() {
+	if r.retries != nil {
+		r.retries.Add(1)
+	}
+	if r.cont == nil {
+		return
+	}
+	opts := &r.cont.opts
+	r.attempts++
+	if opts.maxRetries > 0 && r.attempts >= opts.maxRetries && !r.locked {
+		r.lock()
+	}
+	switch {
+	case opts.backoffBase > 0:
+		d := opts.backoffMax
+		if shift := r.attempts - 1; shift < 32 {
+			if next := opts.backoffBase << shift; next > 0 && next < d {
+				d = next
+			}
+		}
+		time.Sleep(d)
+	case opts.yield:
+		runtime.Gosched()
+	}
+}
+
+// release leaves the fallback path if the operation entered it.
+func (r *casRetry) release() {
+	if r.locked {
+		r.cont.mu.Unlock()
+		r.cont.fallback.Add(-1)
+		r.locked = false
+	}
+}
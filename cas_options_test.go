@@ -0,0 +1,145 @@
+package mapx
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCASMap_WithOptions(t *testing.T) {
+	m := NewCASMapWithOptions[string, int](WithMaxRetries(3), WithYield(), WithBackoff(time.Microsecond, time.Millisecond))
+	want := casOptions{maxRetries: 3, yield: true, backoffBase: time.Microsecond, backoffMax: time.Millisecond}
+	if m.contention.opts != want {
+		t.Errorf("Expected options %+v, got %+v", want, m.contention.opts)
+	}
+
+	// Clones keep the settings but not the fallback state
+	c := m.Clone()
+	if c.contention == m.contention || c.contention.opts != want {
+		t.Errorf("Expected clone to have its own contention state with options %+v", want)
+	}
+
+	if NewCASMap[string, int]().contention != nil {
+		t.Error("Expected maps created without options to have no contention state")
+	}
+}
+
+func TestCASMap_WithOptionsContended(t *testing.T) {
+	options := map[string][]CASOption{
+		"MaxRetries": {WithMaxRetries(1)},
+		"Yield":      {WithYield()},
+		"Backoff":    {WithBackoff(time.Microsecond, 100*time.Microsecond)},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			m := NewCASMapWithOptions[int, int](opts...)
+			const goroutines, increments = 8, 200
+
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < increments; i++ {
+						m.Update(0, func(old int, _ bool) int { return old + 1 })
+					}
+				}()
+			}
+			wg.Wait()
+
+			if v, _ := m.Get(0); v != goroutines*increments {
+				t.Errorf("Expected %d, got %d", goroutines*increments, v)
+			}
+			if n := m.contention.fallback.Load(); n != 0 {
+				t.Errorf("Expected no writes left in the fallback path, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCASRetry_Backoff(t *testing.T) {
+	cont := &casContention{opts: casOptions{maxRetries: 2}}
+	r := cont.begin()
+	r.backoff()
+	if r.locked {
+		t.Error("Expected no fallback before reaching the retry limit")
+	}
+	r.backoff()
+	if !r.locked || cont.fallback.Load() != 1 {
+		t.Error("Expected fallback after reaching the retry limit")
+	}
+
+	// While a write is in the fallback path, new writes wait for the mutex before their first attempt
+	entered := make(chan struct{})
+	go func() {
+		other := cont.begin()
+		defer other.release()
+		close(entered)
+	}()
+	select {
+	case <-entered:
+		t.Fatal("Expected new write to wait for the fallback mutex")
+	case <-time.After(10 * time.Millisecond):
+	}
+	r.release()
+	<-entered
+}
+
+func TestCASRetry_BackoffDelay(t *testing.T) {
+	const base = 2 * time.Millisecond
+	for _, max := range []time.Duration{0, time.Millisecond, 4 * base} {
+		var o casOptions
+		WithBackoff(base, max)(&o)
+		cont := &casContention{opts: o}
+		r := cont.begin()
+
+		// A max below base must not turn the backoff into a spin without sleeping
+		start := time.Now()
+		r.backoff()
+		if elapsed := time.Since(start); elapsed < base {
+			t.Errorf("max %v: expected the first retry to sleep at least %v, slept %v", max, base, elapsed)
+		}
+		r.release()
+	}
+
+	var o casOptions
+	WithBackoff(base, 0)(&o)
+	if o.backoffMax != base {
+		t.Errorf("Expected max 0 to be raised to base %v, got %v", base, o.backoffMax)
+	}
+}
+
+func TestCASMap_Stats(t *testing.T) {
+	m := NewCASMap[int, int]()
+	m.Set(1, 1)
+	m.Delete(2)
+	if stats := m.Stats(); stats.Writes != 2 || stats.Retries != 0 {
+		t.Errorf("Expected 2 writes and no retries, got %+v", stats)
+	}
+
+	// Yielding after every write forces the goroutines to interleave between load and CAS
+	const goroutines, increments = 8, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				m.Update(0, func(old int, _ bool) int {
+					runtime.Gosched()
+					return old + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := m.Stats()
+	if stats.Writes != 2+goroutines*increments {
+		t.Errorf("Expected %d writes, got %d", 2+goroutines*increments, stats.Writes)
+	}
+	if stats.Retries == 0 {
+		t.Error("Expected contended updates to retry")
+	}
+}
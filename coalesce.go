@@ -0,0 +1,110 @@
+package mapx
+
+import (
+	"sync"
+	"time"
+)
+
+// Coalescer buffers Sets to a Map and applies them in batches, so that a burst of Sets
+// to the same key within the coalescing window costs a single copy-on-write update.
+//
+// Sets made through the Coalescer are kept in a pending buffer where later Sets to a key
+// overwrite earlier ones. The first Set after a flush schedules a background flush after
+// the window elapses, which applies all pending values in one update of the underlying map.
+//
+// Reads go directly to the underlying map and may therefore lag behind Sets made through
+// the Coalescer by up to the window. Writes made directly to the underlying map are not
+// ordered with pending Sets; use Coalescer.Delete to remove keys that may have pending Sets.
+type Coalescer[K comparable, V any] struct {
+	m      Map[K, V]
+	window time.Duration
+
+	flushMu sync.Mutex // serializes flushes so batches are applied in order
+
+	mu      sync.Mutex
+	pending map[K]V
+	timer   *time.Timer // non-nil while a flush is scheduled
+	closed  bool
+}
+
+// WithCoalesce returns a Coalescer that coalesces Sets to m within the given window.
+// Call Close when done to apply pending Sets and stop scheduling flushes.
+func WithCoalesce[K comparable, V any](m Map[K, V], window time.Duration) *Coalescer[K, V] {
+	return &Coalescer[K, V]{
+		m:       m,
+		window:  window,
+		pending: make(map[K]V),
+	}
+}
+
+// Set buffers the value for the given key until the next flush.
+// A pending value for the same key is overwritten, so only the latest one is applied.
+// After Close, Set writes through to the underlying map immediately.
+func (c *Coalescer[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		c.flushMu.Lock()
+		defer c.flushMu.Unlock()
+		c.m.Set(key, value)
+		return
+	}
+	c.pending[key] = value
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.window, c.Flush)
+	}
+	c.mu.Unlock()
+}
+
+// Delete discards any pending value for the given key and removes it from the underlying map.
+func (c *Coalescer[K, V]) Delete(key K) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	c.m.Delete(key)
+}
+
+// Flush applies all pending values to the underlying map in a single update.
+// It is called automatically when the window elapses, but may be called at any time.
+func (c *Coalescer[K, V]) Flush() {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.flush(false)
+}
+
+// Close applies all pending values and stops scheduling background flushes.
+// Subsequent Sets write through to the underlying map.
+func (c *Coalescer[K, V]) Close() {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.flush(true)
+}
+
+// flush takes the pending values and applies them in a single update.
+// Must be called with flushMu held so that batches are applied in order.
+func (c *Coalescer[K, V]) flush(closing bool) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[K]V)
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if closing {
+		c.closed = true
+	}
+	c.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	keys := make([]K, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	c.m.compute(keys, func(key K, _ V, _ bool) (V, bool) {
+		return pending[key], true
+	})
+}
@@ -0,0 +1,106 @@
+package mapx
+
+import (
+	"testing"
+	"time"
+)
+
+// countingMap wraps a Map and counts the atomic updates applied through compute.
+type countingMap[K comparable, V any] struct {
+	Map[K, V]
+	updates int
+}
+
+func (m *countingMap[K, V]) compute(keys []K, f func(key K, value V, exists bool) (V, bool)) bool {
+	m.updates++
+	return m.Map.compute(keys, f)
+}
+
+func TestCoalescer_Flush(t *testing.T) {
+	m := &countingMap[string, int]{Map: NewCASMap[string, int]()}
+	// Use a long window so only explicit flushes apply values
+	c := WithCoalesce[string, int](m, time.Hour)
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set("gauge", i)
+	}
+	c.Set("other", 1)
+
+	// Pending Sets are not visible before the flush
+	if m.Has("gauge") {
+		t.Error("Expected gauge to not be visible before flush")
+	}
+
+	c.Flush()
+
+	if val, ok := m.Get("gauge"); !ok || val != 99 {
+		t.Errorf("Expected (99, true), got (%d, %v)", val, ok)
+	}
+	if val, ok := m.Get("other"); !ok || val != 1 {
+		t.Errorf("Expected (1, true), got (%d, %v)", val, ok)
+	}
+	if m.updates != 1 {
+		t.Errorf("Expected 1 update, got %d", m.updates)
+	}
+
+	// Flush with nothing pending should not touch the map
+	c.Flush()
+	if m.updates != 1 {
+		t.Errorf("Expected 1 update, got %d", m.updates)
+	}
+}
+
+func TestCoalescer_Window(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	c := WithCoalesce[string, int](m, 10*time.Millisecond)
+	defer c.Close()
+
+	c.Set("gauge", 1)
+	c.Set("gauge", 2)
+
+	deadline := time.Now().Add(time.Second)
+	for !m.Has("gauge") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected background flush to apply pending Set")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if val, _ := m.Get("gauge"); val != 2 {
+		t.Errorf("Expected value 2, got %d", val)
+	}
+}
+
+func TestCoalescer_Delete(t *testing.T) {
+	m := NewCASMap[string, int]()
+	c := WithCoalesce[string, int](m, time.Hour)
+	defer c.Close()
+
+	m.Set("key1", 100)
+	c.Set("key1", 200)
+	c.Delete("key1")
+	c.Flush()
+
+	// Delete must discard the pending Set instead of letting the flush resurrect the key
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+}
+
+func TestCoalescer_Close(t *testing.T) {
+	m := NewCASMap[string, int]()
+	c := WithCoalesce[string, int](m, time.Hour)
+
+	c.Set("key1", 100)
+	c.Close()
+
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected Close to flush (100, true), got (%d, %v)", val, ok)
+	}
+
+	// Sets after Close write through immediately
+	c.Set("key2", 200)
+	if val, ok := m.Get("key2"); !ok || val != 200 {
+		t.Errorf("Expected (200, true), got (%d, %v)", val, ok)
+	}
+}
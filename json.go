@@ -0,0 +1,108 @@
+package mapx
+
+import (
+	"bufio"
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// jsonEntry is an entry of a map being streamed by writeJSON, with its key already converted to
+// the JSON object key.
+type jsonEntry[K comparable] struct {
+	name string
+	key  K
+}
+
+// writeJSON streams data to w as a JSON object, producing the same output as json.Marshal(data):
+// keys are converted with the same rules and sorted, and values are encoded one at a time, so only
+// the keys and a single encoded value are held in memory instead of the whole document.
+func writeJSON[K comparable, V any](w io.Writer, data map[K]V) error {
+	if err := checkJSONKey[K, V](); err != nil {
+		return err
+	}
+	entries := make([]jsonEntry[K], 0, len(data))
+	for k := range data {
+		name, err := jsonKeyName(k)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, jsonEntry[K]{name: name, key: k})
+	}
+	slices.SortFunc(entries, func(a, b jsonEntry[K]) int {
+		return cmp.Compare(a.name, b.name)
+	})
+
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		name, err := json.Marshal(e.name)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(data[e.key])
+		if err != nil {
+			return err
+		}
+		bw.Write(name)
+		bw.WriteByte(':')
+		// bufio.Writer keeps the first write error, so checking once per entry stops early
+		if _, err := bw.Write(value); err != nil {
+			return err
+		}
+	}
+	bw.WriteByte('}')
+	return bw.Flush()
+}
+
+// textMarshalerType is the reflect.Type of encoding.TextMarshaler.
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// checkJSONKey reports the error json.Marshal returns for a map[K]V if K can't be a JSON object key.
+// Like encoding/json, it accepts strings, integers and types implementing encoding.TextMarshaler.
+func checkJSONKey[K comparable, V any]() error {
+	t := reflect.TypeFor[K]()
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return nil
+	}
+	if t.Implements(textMarshalerType) {
+		return nil
+	}
+	return &json.UnsupportedTypeError{Type: reflect.TypeFor[map[K]V]()}
+}
+
+// jsonKeyName converts a key accepted by checkJSONKey to its JSON object key.
+func jsonKeyName[K comparable](key K) (string, error) {
+	v := reflect.ValueOf(&key).Elem()
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	if tm, ok := any(key).(encoding.TextMarshaler); ok {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		if err != nil {
+			return "", &json.MarshalerError{Type: v.Type(), Err: err}
+		}
+		return string(text), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("mapx: unsupported JSON key type %v", v.Type())
+}
@@ -0,0 +1,75 @@
+package mapx
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoaderPanicked is returned to callers that waited on a loader call which panicked.
+var errLoaderPanicked = errors.New("mapx: loader panicked")
+
+// loadCall is a loader call in flight for a single key, shared by every caller waiting on it.
+type loadCall[V any] struct {
+	done  chan struct{} // closed once value and err are set
+	value V
+	err   error
+}
+
+// loadGroup deduplicates concurrent loads of the same key for GetOrLoad,
+// so that only one loader call per key is in flight at any time.
+type loadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*loadCall[V] // lazily initialized
+}
+
+// do calls fn for key unless a call for key is already in flight, in which case it waits for
+// that call and returns its result. Callers arriving after the call finished start a new one.
+func (g *loadGroup[K, V]) do(key K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[K]*loadCall[V])
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	// Release waiters even if fn panics, so they don't block forever
+	finished := false
+	defer func() {
+		if !finished {
+			call.err = errLoaderPanicked
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	finished = true
+	return call.value, call.err
+}
+
+// getOrLoad implements GetOrLoad for any map: it returns the value for key if present, and otherwise
+// runs loader once for all concurrent callers missing the same key and stores its result with GetOrSet.
+func getOrLoad[K comparable, V any](m Map[K, V], g *loadGroup[K, V], key K, loader func(key K) (V, error)) (V, error) {
+	if v, ok := m.Get(key); ok {
+		return v, nil
+	}
+	return g.do(key, func() (V, error) {
+		// The key may have been stored by a load that finished after the check above
+		if v, ok := m.Get(key); ok {
+			return v, nil
+		}
+		v, err := loader(key)
+		if err != nil {
+			return v, err
+		}
+		v, _ = m.GetOrSet(key, v)
+		return v, nil
+	})
+}
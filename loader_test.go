@@ -0,0 +1,121 @@
+package mapx
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadable is implemented by the maps that support GetOrLoad.
+type loadable[K comparable, V any] interface {
+	Map[K, V]
+	GetOrLoad(key K, loader func(key K) (V, error)) (V, error)
+}
+
+func TestGetOrLoad_Coalesces(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m loadable[string, int]) {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func(key string) (int, error) {
+			calls.Add(1)
+			<-release
+			return 100, nil
+		}
+
+		const goroutines = 50
+		var started, wg sync.WaitGroup
+		started.Add(goroutines)
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func() {
+				defer wg.Done()
+				started.Done()
+				if v, err := m.GetOrLoad("key1", loader); err != nil || v != 100 {
+					t.Errorf("Expected (100, nil), got (%d, %v)", v, err)
+				}
+			}()
+		}
+		started.Wait()
+		// Give the goroutines time to join the in-flight load before it completes
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("Expected loader to run once, ran %d times", n)
+		}
+		if v, ok := m.Get("key1"); !ok || v != 100 {
+			t.Errorf("Expected loaded value to be stored, got (%d, %v)", v, ok)
+		}
+	})
+}
+
+func TestGetOrLoad_Existing(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m loadable[string, int]) {
+		m.Set("key1", 100)
+		v, err := m.GetOrLoad("key1", func(key string) (int, error) {
+			t.Error("Expected loader not to be called for an existing key")
+			return 0, nil
+		})
+		if err != nil || v != 100 {
+			t.Errorf("Expected (100, nil), got (%d, %v)", v, err)
+		}
+	})
+}
+
+func TestGetOrLoad_ErrorNotCached(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m loadable[string, int]) {
+		errLoad := errors.New("load failed")
+		if _, err := m.GetOrLoad("key1", func(key string) (int, error) {
+			return 0, errLoad
+		}); !errors.Is(err, errLoad) {
+			t.Errorf("Expected load error, got %v", err)
+		}
+		if m.Has("key1") {
+			t.Error("Expected nothing to be stored after a failed load")
+		}
+
+		// The next call loads again
+		if v, err := m.GetOrLoad("key1", func(key string) (int, error) {
+			return 200, nil
+		}); err != nil || v != 200 {
+			t.Errorf("Expected (200, nil), got (%d, %v)", v, err)
+		}
+	})
+}
+
+func TestGetOrLoad_PanicReleasesWaiters(t *testing.T) {
+	var g loadGroup[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.do("key1", func() (int, error) {
+			close(started)
+			<-release
+			panic("loader failed")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := g.do("key1", func() (int, error) { return 0, nil })
+		done <- err
+	}()
+	// Give the second caller time to join the in-flight call before it panics
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errLoaderPanicked) {
+			t.Errorf("Expected errLoaderPanicked, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Waiter blocked after the loader panicked")
+	}
+}
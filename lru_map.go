@@ -0,0 +1,169 @@
+package mapx
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUMap is a concurrent-safe map with a maximum size that evicts the least recently used
+// entry when a Set would exceed it.
+//
+// Unlike the copy-on-write maps, every Get updates the recency order, so all operations take
+// a Mutex. Each operation is O(1) and allocation-free on hits, which keeps Get fast under
+// read-heavy load as long as the critical section stays short.
+type LRUMap[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[K]*list.Element
+	order    *list.List // front is the most recently used entry
+	onEvict  func(key K, value V)
+}
+
+// lruEntry is a key-value pair stored in the recency list of an LRUMap.
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRUMap creates a new LRUMap instance holding at most capacity entries.
+// A capacity below 1 is treated as 1.
+func NewLRUMap[K comparable, V any](capacity int) *LRUMap[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUMap[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// OnEvict registers a callback invoked with each entry evicted to make room for a new one.
+// The callback runs after the map's lock is released, so it may call methods of the map.
+// Entries removed by Delete or Clear are not reported.
+func (m *LRUMap[K, V]) OnEvict(f func(key K, value V)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEvict = f
+}
+
+// Get retrieves the value associated with the given key and marks it as most recently used.
+// Returns the zero value and false if the key doesn't exist; otherwise returns the value and true.
+func (m *LRUMap[K, V]) Get(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	m.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry[K, V]).value, true
+}
+
+// Peek retrieves the value associated with the given key without updating its recency.
+func (m *LRUMap[K, V]) Peek(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return elem.Value.(*lruEntry[K, V]).value, true
+}
+
+// Has checks whether the given key exists in the map without updating its recency.
+func (m *LRUMap[K, V]) Has(key K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.items[key]
+	return ok
+}
+
+// Set associates the given value with the given key and marks it as most recently used.
+// If the key already exists, the old value will be overwritten. If adding the key exceeds the
+// capacity, the least recently used entry is evicted and reported to the OnEvict callback.
+func (m *LRUMap[K, V]) Set(key K, value V) {
+	m.mu.Lock()
+	if elem, ok := m.items[key]; ok {
+		elem.Value.(*lruEntry[K, V]).value = value
+		m.order.MoveToFront(elem)
+		m.mu.Unlock()
+		return
+	}
+	m.items[key] = m.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if m.order.Len() <= m.capacity {
+		m.mu.Unlock()
+		return
+	}
+	oldest := m.order.Remove(m.order.Back()).(*lruEntry[K, V])
+	delete(m.items, oldest.key)
+	onEvict := m.onEvict
+	m.mu.Unlock()
+
+	if onEvict != nil {
+		onEvict(oldest.key, oldest.value)
+	}
+}
+
+// Delete removes the given key from the map.
+// Has no effect if the key doesn't exist.
+func (m *LRUMap[K, V]) Delete(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.order.Remove(elem)
+		delete(m.items, key)
+	}
+}
+
+// Len returns the number of key-value pairs in the map.
+func (m *LRUMap[K, V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+// Cap returns the maximum number of key-value pairs the map holds.
+func (m *LRUMap[K, V]) Cap() int {
+	return m.capacity
+}
+
+// Clear removes all key-value pairs from the map.
+func (m *LRUMap[K, V]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = make(map[K]*list.Element, m.capacity)
+	m.order.Init()
+}
+
+// Keys returns a slice containing all keys in the map, from most to least recently used.
+func (m *LRUMap[K, V]) Keys() []K {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]K, 0, m.order.Len())
+	for elem := m.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*lruEntry[K, V]).key)
+	}
+	return keys
+}
+
+// Range iterates over all key-value pairs in the map, from most to least recently used,
+// without updating their recency. Calls f for each pair, stopping iteration if f returns false.
+// Note: iteration is over a snapshot taken under the lock, so it's safe to call any method
+// of the map within f without deadlock.
+func (m *LRUMap[K, V]) Range(f func(key K, value V) bool) {
+	m.mu.Lock()
+	entries := make([]lruEntry[K, V], 0, m.order.Len())
+	for elem := m.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, *elem.Value.(*lruEntry[K, V]))
+	}
+	m.mu.Unlock()
+
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			break
+		}
+	}
+}
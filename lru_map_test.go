@@ -0,0 +1,154 @@
+package mapx
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLRUMap_BasicOperations(t *testing.T) {
+	m := NewLRUMap[string, int](10)
+
+	m.Set("key1", 100)
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+	if val, ok := m.Get("key2"); ok {
+		t.Errorf("Expected (0, false), got (%d, true)", val)
+	}
+	if !m.Has("key1") {
+		t.Error("Expected key1 to exist")
+	}
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1, got %d", m.Len())
+	}
+
+	m.Set("key1", 200)
+	if val, _ := m.Peek("key1"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+
+	m.Delete("key1")
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+
+	m.Set("key2", 1)
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+}
+
+func TestLRUMap_Eviction(t *testing.T) {
+	m := NewLRUMap[string, int](3)
+
+	var evicted []string
+	m.OnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+
+	// Access a so that b becomes the least recently used
+	m.Get("a")
+	m.Set("d", 4)
+
+	if m.Has("b") {
+		t.Error("Expected b to be evicted")
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("Expected [b] to be evicted, got %v", evicted)
+	}
+
+	// Peek doesn't count as an access, so c is evicted next
+	m.Peek("c")
+	m.Set("e", 5)
+	if m.Has("c") {
+		t.Error("Expected c to be evicted")
+	}
+
+	keys := m.Keys()
+	expected := []string{"e", "d", "a"}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected keys %v, got %v", expected, keys)
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("Expected keys %v, got %v", expected, keys)
+			break
+		}
+	}
+	if m.Len() != m.Cap() {
+		t.Errorf("Expected length %d, got %d", m.Cap(), m.Len())
+	}
+}
+
+func TestLRUMap_UpdateRefreshesRecency(t *testing.T) {
+	m := NewLRUMap[string, int](2)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("a", 10) // a becomes the most recently used
+	m.Set("c", 3)
+
+	if !m.Has("a") || m.Has("b") {
+		t.Error("Expected b to be evicted instead of the updated a")
+	}
+}
+
+func TestLRUMap_Range(t *testing.T) {
+	m := NewLRUMap[string, int](10)
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	sum := 0
+	m.Range(func(key string, value int) bool {
+		sum += value
+		// Writing from the callback must not deadlock
+		m.Set(key+"-copy", value)
+		return true
+	})
+	if sum != 600 {
+		t.Errorf("Expected sum 600, got %d", sum)
+	}
+
+	count := 0
+	m.Range(func(key string, value int) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Expected to visit 1 entry, visited %d", count)
+	}
+}
+
+func TestLRUMap_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	const capacity = 100
+	m := NewLRUMap[int, int](capacity)
+	const goroutines = 10
+	const iterations = 1000
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := id*iterations + j
+				m.Set(key, key)
+				m.Get(key - 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if m.Len() != capacity {
+		t.Errorf("Expected length %d, got %d", capacity, m.Len())
+	}
+}
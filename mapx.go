@@ -1 +1,395 @@
 package mapx
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Map is the common interface implemented by the concurrent map types in this package.
+//
+// It allows writing helpers that work with any implementation, such as the package-level
+// generic functions that need type parameters Go methods cannot declare.
+type Map[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, value V)
+	Delete(key K)
+	Len() int
+	Has(key K) bool
+	Clear()
+
+	// Range iterates over a snapshot of the map, so f may safely call any method of the map,
+	// including write methods, without deadlocking. Every implementation must honor this.
+	Range(f func(key K, value V) bool)
+
+	Keys() []K
+	Values() []V
+	GetOrSet(key K, value V) (V, bool)
+	SetIfAbsent(key K, value V) bool
+	CompareAndSwap(key K, oldValue, newValue V) bool
+
+	// compute atomically replaces the values of keys with the ones returned by f, storing all
+	// changes in a single update (one per shard for ShardedMap). f receives each key with its
+	// current value and whether it exists, and returns the new value and whether it should be
+	// stored. keys must not contain duplicates.
+	// Returns true if any new value was stored.
+	// f may be called more than once per key by lock-free implementations.
+	compute(keys []K, f func(key K, value V, exists bool) (V, bool)) bool
+
+	// update atomically applies the op returned by f to key. f receives the current value and
+	// whether the key exists, and returns the new value and whether to keep, store or delete it.
+	// Returns true if the map was changed.
+	// f may be called more than once by lock-free implementations.
+	update(key K, f func(value V, exists bool) (V, updateOp)) bool
+}
+
+// updateOp tells update what to do with a key.
+type updateOp int
+
+const (
+	updateKeep   updateOp = iota // leave the key unchanged
+	updateStore                  // store the new value
+	updateDelete                 // delete the key
+)
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	Integer | ~float32 | ~float64
+}
+
+// CompareFieldAndSwap atomically sets newValue for key only if the key exists and the field
+// extracted from the current value by getField equals expected.
+// Returns true if the swap succeeded, false if the key doesn't exist or the field doesn't match.
+//
+// This enables optimistic concurrency on a part of the value (for example a version number)
+// without requiring the whole value to be comparable.
+func CompareFieldAndSwap[K comparable, V any, F comparable](m Map[K, V], key K, getField func(V) F, expected F, newValue V) bool {
+	return m.compute([]K{key}, func(_ K, value V, exists bool) (V, bool) {
+		return newValue, exists && getField(value) == expected
+	})
+}
+
+// AddMany adds each delta to the value of the corresponding key, treating missing keys as zero.
+// How atomic the batch is depends on the implementation: CASMap, RWMutexMap and SmallMap apply all
+// deltas in a single copy-on-write update, amortizing the copy across the batch instead of paying for
+// one copy per increment, while ShardedMap applies one update per shard, so readers may see the deltas
+// of some shards before the others. Each delta is always added atomically. Like Increment, it accepts
+// floating-point values, which are added without being compared.
+func AddMany[K comparable, V Number](m Map[K, V], deltas map[K]V) {
+	if len(deltas) == 0 {
+		return
+	}
+	keys := make([]K, 0, len(deltas))
+	for k := range deltas {
+		keys = append(keys, k)
+	}
+	m.compute(keys, func(key K, value V, _ bool) (V, bool) {
+		return value + deltas[key], true
+	})
+}
+
+// Increment atomically adds delta to the value of key, treating a missing key as zero,
+// and returns the new value.
+// It works for integer and floating-point counters alike: the update is applied in place of the
+// current value rather than through CompareAndSwap, so it never compares values and floats
+// (including NaN) need no custom equality.
+func Increment[K comparable, V Number](m Map[K, V], key K, delta V) V {
+	var result V
+	m.compute([]K{key}, func(_ K, value V, _ bool) (V, bool) {
+		result = value + delta
+		return result, true
+	})
+	return result
+}
+
+// UpsertNested atomically sets innerKey to value in the nested map stored under outerKey,
+// creating the nested map if outerKey doesn't exist.
+// The atomicity rests on the implementation's single-key update: CASMap, RWMutexMap and SmallMap
+// store it in one copy-on-write update of the map, and ShardedMap in one of the key's shard.
+// The nested map is copied before it is modified, because it is shared with every snapshot and
+// reader that has already loaded it; mutating it in place would corrupt them.
+func UpsertNested[K, IK comparable, IV any](m Map[K, map[IK]IV], outerKey K, innerKey IK, value IV) {
+	m.compute([]K{outerKey}, func(_ K, inner map[IK]IV, _ bool) (map[IK]IV, bool) {
+		newInner := make(map[IK]IV, len(inner)+1)
+		for k, v := range inner {
+			newInner[k] = v
+		}
+		newInner[innerKey] = value
+		return newInner, true
+	})
+}
+
+// AppendValue atomically appends items to the slice stored under key, creating it if key doesn't exist.
+// The slice is copied before appending, because its backing array is shared with every snapshot and
+// reader that has already loaded it; appending in place could overwrite elements they still see.
+func AppendValue[K comparable, T any](m Map[K, []T], key K, items ...T) {
+	if len(items) == 0 {
+		return
+	}
+	m.compute([]K{key}, func(_ K, old []T, _ bool) ([]T, bool) {
+		newSlice := make([]T, 0, len(old)+len(items))
+		newSlice = append(newSlice, old...)
+		return append(newSlice, items...), true
+	})
+}
+
+// RemoveValue atomically removes the first element equal to item from the slice stored under key,
+// deleting the key entirely if the slice becomes empty.
+// Returns true if an element was removed, false if the key doesn't exist or its slice doesn't contain item.
+// The slice is rebuilt rather than modified in place, because its backing array is shared with every
+// snapshot and reader that has already loaded it.
+func RemoveValue[K comparable, T comparable](m Map[K, []T], key K, item T) bool {
+	return m.update(key, func(old []T, _ bool) ([]T, updateOp) {
+		i := slices.Index(old, item)
+		if i < 0 {
+			return nil, updateKeep
+		}
+		if len(old) == 1 {
+			return nil, updateDelete
+		}
+		newSlice := make([]T, 0, len(old)-1)
+		newSlice = append(newSlice, old[:i]...)
+		return append(newSlice, old[i+1:]...), updateStore
+	})
+}
+
+// MapValues returns a newly allocated plain map with the result of f for each entry of a snapshot of m.
+// f runs on the snapshot without holding any lock, so it may call any method of m.
+func MapValues[K comparable, V, R any](m Map[K, V], f func(key K, value V) R) map[K]R {
+	result := make(map[K]R, m.Len())
+	m.Range(func(key K, value V) bool {
+		result[key] = f(key, value)
+		return true
+	})
+	return result
+}
+
+// Reduce folds over a snapshot of m, calling f for each entry with the accumulator returned by
+// the previous call (initial for the first one), and returns the final accumulator.
+// Iteration order is unspecified, so f should not depend on the order of entries.
+func Reduce[K comparable, V, A any](m Map[K, V], initial A, f func(acc A, key K, value V) A) A {
+	acc := initial
+	m.Range(func(key K, value V) bool {
+		acc = f(acc, key, value)
+		return true
+	})
+	return acc
+}
+
+// SizeBytes returns an estimate of the memory held by the contents of m, summing sizeof over the
+// entries of a snapshot. Go can't measure the size of arbitrary values, so sizeof is expected to
+// account for the key and value of each entry, including anything they reference. The overhead of
+// the map itself is not included.
+func SizeBytes[K comparable, V any](m Map[K, V], sizeof func(key K, value V) int) int64 {
+	var total int64
+	m.Range(func(key K, value V) bool {
+		total += int64(sizeof(key, value))
+		return true
+	})
+	return total
+}
+
+// ScanPrefix returns a newly allocated plain map with the entries of a snapshot of m whose key starts
+// with prefix. The maps are not ordered, so this is a linear scan over every entry regardless of how
+// many match. An empty prefix matches every key.
+func ScanPrefix[V any](m Map[string, V], prefix string) map[string]V {
+	result := make(map[string]V)
+	m.Range(func(key string, value V) bool {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = value
+		}
+		return true
+	})
+	return result
+}
+
+// RangeSorted iterates over a snapshot of m in increasing key order, as defined by cmp.Compare.
+// Calls f for each pair, stopping iteration if f returns false.
+// The snapshot is copied and its keys are sorted before iteration starts, so it costs O(n log n)
+// and f may safely call any method of m.
+func RangeSorted[K cmp.Ordered, V any](m Map[K, V], f func(key K, value V) bool) {
+	snapshot := make(map[K]V, m.Len())
+	m.Range(func(key K, value V) bool {
+		snapshot[key] = value
+		return true
+	})
+	for _, key := range slices.Sorted(maps.Keys(snapshot)) {
+		if !f(key, snapshot[key]) {
+			return
+		}
+	}
+}
+
+// Entry is a key-value pair, as returned by SortedEntries and the Entries method of the maps.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// SortedEntries returns the entries of a snapshot of m as a newly allocated slice sorted in
+// increasing key order, as defined by cmp.Compare. The caller owns the returned slice.
+func SortedEntries[K cmp.Ordered, V any](m Map[K, V]) []Entry[K, V] {
+	entries := snapshotEntries(m)
+	slices.SortFunc(entries, func(a, b Entry[K, V]) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return entries
+}
+
+// SortedEntriesFunc is like SortedEntries, but orders keys with less, for key types that are not
+// ordered. The sort is not stable, which doesn't matter as long as less is a strict weak ordering.
+func SortedEntriesFunc[K comparable, V any](m Map[K, V], less func(a, b K) bool) []Entry[K, V] {
+	entries := snapshotEntries(m)
+	slices.SortFunc(entries, func(a, b Entry[K, V]) int {
+		switch {
+		case less(a.Key, b.Key):
+			return -1
+		case less(b.Key, a.Key):
+			return 1
+		}
+		return 0
+	})
+	return entries
+}
+
+// snapshotEntries returns the entries of a snapshot of m in unspecified order.
+func snapshotEntries[K comparable, V any](m Map[K, V]) []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, m.Len())
+	m.Range(func(key K, value V) bool {
+		entries = append(entries, Entry[K, V]{Key: key, Value: value})
+		return true
+	})
+	return entries
+}
+
+// Equal reports whether a and b contain the same keys with equal values.
+// Values are compared with eq, which allows values of non-comparable types; a nil eq uses the
+// package's default comparison (== for comparable values, reflect.DeepEqual otherwise).
+// It compares lengths first and stops at the first difference. The snapshots of a and b are
+// taken one after another, so under concurrent writes the result isn't atomic across both maps.
+func Equal[K comparable, V any](a, b Map[K, V], eq func(x, y V) bool) bool {
+	if eq == nil {
+		eq = compare[V]
+	}
+	if a.Len() != b.Len() {
+		return false
+	}
+	other := make(map[K]V, b.Len())
+	b.Range(func(key K, value V) bool {
+		other[key] = value
+		return true
+	})
+	n := 0
+	equal := true
+	a.Range(func(key K, value V) bool {
+		n++
+		v, ok := other[key]
+		if !ok || !eq(value, v) {
+			equal = false
+			return false
+		}
+		return true
+	})
+	return equal && n == len(other)
+}
+
+// RangePaired calls f with both values for each key present in both a and b, stopping if f returns
+// false. Keys present in only one of the maps are skipped. b is copied into a plain map first and a
+// is then iterated, so f may call any method of either map; the snapshots of a and b are taken one
+// after another, so under concurrent writes the pairs aren't atomic across both maps.
+// The order of keys is unspecified.
+func RangePaired[K comparable, V1, V2 any](a Map[K, V1], b Map[K, V2], f func(key K, a V1, b V2) bool) {
+	other := make(map[K]V2, b.Len())
+	b.Range(func(key K, value V2) bool {
+		other[key] = value
+		return true
+	})
+	a.Range(func(key K, value V1) bool {
+		if v, ok := other[key]; ok {
+			return f(key, value, v)
+		}
+		return true
+	})
+}
+
+// MergeOverwrite is a resolve function for MergeFunc that stores the merged value, as Merge does.
+func MergeOverwrite[K comparable, V any](_ K, _, newValue V) V {
+	return newValue
+}
+
+// MergeSkipExisting is a resolve function for MergeFunc that keeps the current value of keys that
+// already exist, so that only missing keys are added.
+func MergeSkipExisting[K comparable, V any](_ K, oldValue, _ V) V {
+	return oldValue
+}
+
+// MergeKeepMax is a resolve function for MergeFunc that keeps the larger of the current and the
+// merged value.
+func MergeKeepMax[K comparable, V cmp.Ordered](_ K, oldValue, newValue V) V {
+	return max(oldValue, newValue)
+}
+
+// UnionKeys returns the keys present in a, b or both, each exactly once.
+// The order of keys is unspecified, and an empty result is an empty, non-nil slice, as for Keys.
+// The snapshots of a and b are taken one after another, so under concurrent writes the result
+// isn't atomic across both maps.
+func UnionKeys[K comparable, V any](a, b Map[K, V]) []K {
+	seen := keySet(a)
+	keys := make([]K, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	b.Range(func(key K, _ V) bool {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// IntersectKeys returns the keys present in both a and b.
+// The order of keys, the empty result and the snapshots are as in UnionKeys.
+func IntersectKeys[K comparable, V any](a, b Map[K, V]) []K {
+	inB := keySet(b)
+	keys := make([]K, 0)
+	a.Range(func(key K, _ V) bool {
+		if _, ok := inB[key]; ok {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// DifferenceKeys returns the keys present in a but not in b, such as the keys of a desired state
+// that are missing from the actual one. The order of keys, the empty result and the snapshots are
+// as in UnionKeys.
+func DifferenceKeys[K comparable, V any](a, b Map[K, V]) []K {
+	inB := keySet(b)
+	keys := make([]K, 0)
+	a.Range(func(key K, _ V) bool {
+		if _, ok := inB[key]; !ok {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// keySet returns the keys of a snapshot of m as a set.
+func keySet[K comparable, V any](m Map[K, V]) map[K]struct{} {
+	set := make(map[K]struct{}, m.Len())
+	m.Range(func(key K, _ V) bool {
+		set[key] = struct{}{}
+		return true
+	})
+	return set
+}
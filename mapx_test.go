@@ -0,0 +1,726 @@
+package mapx
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// forEachImpl runs test as a subtest, named after the type, on a fresh instance of every Map
+// implementation that satisfies T. T is the Map interface itself or an interface embedding it
+// with the extra methods a test needs, so the test runs on exactly the types that support them.
+func forEachImpl[T Map[K, V], K comparable, V any](t *testing.T, test func(t *testing.T, m T)) {
+	impls := []struct {
+		name string
+		m    Map[K, V]
+	}{
+		{"RWMutexMap", NewRWMutexMap[K, V]()},
+		{"CASMap", NewCASMap[K, V]()},
+		{"SmallMap", NewSmallMap[K, V]()},
+		{"ShardedMap", NewShardedMap[K, V](4)},
+	}
+	for _, impl := range impls {
+		if m, ok := impl.m.(T); ok {
+			t.Run(impl.name, func(t *testing.T) {
+				test(t, m)
+			})
+		}
+	}
+}
+
+type versioned struct {
+	Version int
+	Tags    []string // makes the struct non-comparable
+}
+
+func TestCompareFieldAndSwap(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, versioned]) {
+		version := func(v versioned) int { return v.Version }
+
+		// Swap on non-existent key should fail
+		if CompareFieldAndSwap(m, "key1", version, 0, versioned{Version: 1}) {
+			t.Error("Expected swap to fail on non-existent key")
+		}
+		if m.Has("key1") {
+			t.Error("Expected key1 to not be created")
+		}
+
+		m.Set("key1", versioned{Version: 1, Tags: []string{"a"}})
+
+		// Swap with wrong field value should fail
+		if CompareFieldAndSwap(m, "key1", version, 2, versioned{Version: 3}) {
+			t.Error("Expected swap to fail with wrong version")
+		}
+
+		// Swap with matching field value should succeed
+		if !CompareFieldAndSwap(m, "key1", version, 1, versioned{Version: 2, Tags: []string{"b"}}) {
+			t.Error("Expected swap to succeed")
+		}
+
+		val, _ := m.Get("key1")
+		if val.Version != 2 || len(val.Tags) != 1 || val.Tags[0] != "b" {
+			t.Errorf("Expected version 2 with tag b, got %+v", val)
+		}
+	})
+}
+
+func TestAddMany(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int64]) {
+		m.Set("hits", 10)
+
+		AddMany(m, map[string]int64{"hits": 5, "misses": 3})
+
+		if val, _ := m.Get("hits"); val != 15 {
+			t.Errorf("Expected hits 15, got %d", val)
+		}
+		if val, ok := m.Get("misses"); !ok || val != 3 {
+			t.Errorf("Expected (3, true), got (%d, %v)", val, ok)
+		}
+
+		// Empty deltas should be a no-op
+		AddMany(m, nil)
+		if m.Len() != 2 {
+			t.Errorf("Expected length 2, got %d", m.Len())
+		}
+	})
+}
+
+func TestAddMany_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		const goroutines = 10
+		const iterations = 100
+
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func() {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					AddMany(m, map[string]int{"a": 1, "b": 2})
+				}
+			}()
+		}
+		wg.Wait()
+
+		if val, _ := m.Get("a"); val != goroutines*iterations {
+			t.Errorf("Expected a=%d, got %d", goroutines*iterations, val)
+		}
+		if val, _ := m.Get("b"); val != 2*goroutines*iterations {
+			t.Errorf("Expected b=%d, got %d", 2*goroutines*iterations, val)
+		}
+	})
+}
+
+func TestAddMany_ConcurrentOverlapping(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	forEachImpl(t, func(t *testing.T, m Map[int, int64]) {
+		const goroutines = 10
+		const iterations = 100
+		const keys = 4
+
+		// Each goroutine flushes a different delta map, overlapping on key 0 and on
+		// the keys it shares with its neighbours
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func(id int) {
+				defer wg.Done()
+				deltas := map[int]int64{0: 1, id % keys: int64(id), (id + 1) % keys: 1}
+				for j := 0; j < iterations; j++ {
+					AddMany(m, deltas)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		want := make(map[int]int64)
+		for i := 0; i < goroutines; i++ {
+			deltas := map[int]int64{0: 1, i % keys: int64(i), (i + 1) % keys: 1}
+			for k, d := range deltas {
+				want[k] += d * iterations
+			}
+		}
+		got := make(map[int]int64)
+		m.Range(func(k int, v int64) bool {
+			got[k] = v
+			return true
+		})
+		if !maps.Equal(got, want) {
+			t.Errorf("Expected totals %v, got %v", want, got)
+		}
+	})
+}
+
+func TestAddMany_Float(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, float64]) {
+		m.Set("latency", 1.5)
+
+		AddMany(m, map[string]float64{"latency": 0.25, "errors": 0.5})
+
+		if val, _ := m.Get("latency"); val != 1.75 {
+			t.Errorf("Expected latency 1.75, got %v", val)
+		}
+		if val, ok := m.Get("errors"); !ok || val != 0.5 {
+			t.Errorf("Expected (0.5, true), got (%v, %v)", val, ok)
+		}
+	})
+}
+
+func TestIncrement(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int64]) {
+		// Missing key should be treated as zero
+		if got := Increment(m, "hits", 5); got != 5 {
+			t.Errorf("Expected 5, got %d", got)
+		}
+		if got := Increment(m, "hits", -2); got != 3 {
+			t.Errorf("Expected 3, got %d", got)
+		}
+		if val, _ := m.Get("hits"); val != 3 {
+			t.Errorf("Expected stored value 3, got %d", val)
+		}
+	})
+
+	t.Run("float64", func(t *testing.T) {
+		forEachImpl(t, func(t *testing.T, m Map[string, float64]) {
+			Increment(m, "load", 0.5)
+			if got := Increment(m, "load", 0.25); got != 0.75 {
+				t.Errorf("Expected 0.75, got %v", got)
+			}
+		})
+	})
+}
+
+func TestIncrement_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	forEachImpl(t, func(t *testing.T, m Map[string, int64]) {
+		const goroutines = 20
+		const iterations = 100
+
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func() {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					Increment(m, "counter", 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if val, _ := m.Get("counter"); val != goroutines*iterations {
+			t.Errorf("Expected counter=%d, got %d", goroutines*iterations, val)
+		}
+	})
+
+	t.Run("float64", func(t *testing.T) {
+		forEachImpl(t, func(t *testing.T, m Map[string, float64]) {
+			const goroutines = 20
+			const iterations = 100
+
+			var wg sync.WaitGroup
+			wg.Add(goroutines)
+			for i := 0; i < goroutines; i++ {
+				go func() {
+					defer wg.Done()
+					for j := 0; j < iterations; j++ {
+						// 0.25 is exact in binary, so the sum doesn't depend on the order of additions
+						Increment(m, "accumulator", 0.25)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if val, _ := m.Get("accumulator"); val != goroutines*iterations*0.25 {
+				t.Errorf("Expected accumulator=%v, got %v", goroutines*iterations*0.25, val)
+			}
+		})
+	})
+}
+
+func TestMapValues(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		m.Set("key1", 100)
+		m.Set("key2", 200)
+
+		result := MapValues(m, func(key string, value int) string {
+			// Calling write methods from f must not deadlock
+			m.Set(key+"-seen", value)
+			return key + "=" + strconv.Itoa(value)
+		})
+		if len(result) != 2 || result["key1"] != "key1=100" || result["key2"] != "key2=200" {
+			t.Errorf("Expected transformed values, got %v", result)
+		}
+		if m.Len() != 4 {
+			t.Errorf("Expected length 4, got %d", m.Len())
+		}
+	})
+}
+
+func TestReduce(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		sum := func(acc int, _ string, value int) int { return acc + value }
+
+		// Empty map should return the initial value
+		if got := Reduce(m, 42, sum); got != 42 {
+			t.Errorf("Expected 42, got %d", got)
+		}
+
+		m.Set("key1", 100)
+		m.Set("key2", 200)
+		m.Set("key3", 300)
+
+		if got := Reduce(m, 0, sum); got != 600 {
+			t.Errorf("Expected sum 600, got %d", got)
+		}
+
+		// Count of matching entries
+		count := Reduce(m, 0, func(acc int, _ string, value int) int {
+			if value >= 200 {
+				acc++
+			}
+			return acc
+		})
+		if count != 2 {
+			t.Errorf("Expected 2 matching entries, got %d", count)
+		}
+
+		// Accumulator of a different type
+		keys := Reduce(m, map[string]bool{}, func(acc map[string]bool, key string, _ int) map[string]bool {
+			acc[key] = true
+			return acc
+		})
+		if len(keys) != 3 || !keys["key1"] || !keys["key2"] || !keys["key3"] {
+			t.Errorf("Expected all 3 keys, got %v", keys)
+		}
+	})
+}
+
+func TestSizeBytes(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		// Each entry is its key's bytes plus an 8-byte value
+		sizeof := func(key string, _ int) int { return len(key) + 8 }
+
+		if got := SizeBytes(m, sizeof); got != 0 {
+			t.Errorf("Expected 0 for an empty map, got %d", got)
+		}
+
+		m.Set("a", 1)
+		m.Set("bb", 2)
+		m.Set("ccc", 3)
+
+		if got := SizeBytes(m, sizeof); got != 6+3*8 {
+			t.Errorf("Expected %d, got %d", 6+3*8, got)
+		}
+	})
+}
+
+func TestScanPrefix(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		m.Set("user:1", 1)
+		m.Set("user:1:session", 2)
+		m.Set("user:12", 3)
+		m.Set("group:1", 4)
+
+		got := ScanPrefix(m, "user:1:")
+		if len(got) != 1 || got["user:1:session"] != 2 {
+			t.Errorf("Expected only user:1:session, got %v", got)
+		}
+
+		// Overlapping prefixes match every key they start
+		got = ScanPrefix(m, "user:1")
+		if len(got) != 3 || got["user:1"] != 1 || got["user:1:session"] != 2 || got["user:12"] != 3 {
+			t.Errorf("Expected the three user:1 keys, got %v", got)
+		}
+
+		if got := ScanPrefix(m, ""); len(got) != 4 {
+			t.Errorf("Expected the empty prefix to match everything, got %v", got)
+		}
+		if got := ScanPrefix(m, "missing"); got == nil || len(got) != 0 {
+			t.Errorf("Expected an empty non-nil map, got %v", got)
+		}
+	})
+}
+
+func TestRangeSorted(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[int, string]) {
+		for _, k := range []int{42, 7, 19, -3, 100, 0} {
+			m.Set(k, strconv.Itoa(k))
+		}
+
+		var keys []int
+		RangeSorted(m, func(key int, value string) bool {
+			if value != strconv.Itoa(key) {
+				t.Errorf("Expected value %q for key %d, got %q", strconv.Itoa(key), key, value)
+			}
+			keys = append(keys, key)
+			return true
+		})
+		if len(keys) != 6 {
+			t.Fatalf("Expected 6 keys, got %v", keys)
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1] >= keys[i] {
+				t.Errorf("Expected strictly increasing keys, got %v", keys)
+				break
+			}
+		}
+
+		// Early termination stops after the smallest keys
+		keys = keys[:0]
+		RangeSorted(m, func(key int, _ string) bool {
+			keys = append(keys, key)
+			return len(keys) < 2
+		})
+		if len(keys) != 2 || keys[0] != -3 || keys[1] != 0 {
+			t.Errorf("Expected [-3 0], got %v", keys)
+		}
+	})
+}
+
+func TestSortedEntries(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[int, string]) {
+		if entries := SortedEntries(m); len(entries) != 0 {
+			t.Errorf("Expected no entries, got %v", entries)
+		}
+
+		for _, k := range []int{42, 7, 19, -3} {
+			m.Set(k, strconv.Itoa(k))
+		}
+
+		entries := SortedEntries(m)
+		expected := []Entry[int, string]{{-3, "-3"}, {7, "7"}, {19, "19"}, {42, "42"}}
+		if !slices.Equal(entries, expected) {
+			t.Errorf("Expected %v, got %v", expected, entries)
+		}
+
+		// The result is independent of later writes
+		m.Set(0, "0")
+		m.Delete(42)
+		if !slices.Equal(entries, expected) {
+			t.Errorf("Expected entries to be unaffected by writes, got %v", entries)
+		}
+
+		// Descending order with a custom less
+		desc := SortedEntriesFunc(m, func(a, b int) bool { return a > b })
+		expected = []Entry[int, string]{{19, "19"}, {7, "7"}, {0, "0"}, {-3, "-3"}}
+		if !slices.Equal(desc, expected) {
+			t.Errorf("Expected %v, got %v", expected, desc)
+		}
+	})
+}
+
+func TestEqual(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, a Map[string, []int]) {
+		b := NewCASMap[string, []int]()
+
+		// Two empty maps are equal
+		if !Equal[string, []int](a, b, nil) {
+			t.Error("Expected empty maps to be equal")
+		}
+
+		a.Set("key1", []int{1})
+		a.Set("key2", []int{2})
+		b.Set("key1", []int{1})
+		b.Set("key2", []int{2})
+		if !Equal[string, []int](a, b, nil) {
+			t.Error("Expected maps with the same contents to be equal")
+		}
+
+		// Different lengths
+		b.Set("key3", []int{3})
+		if Equal[string, []int](a, b, nil) {
+			t.Error("Expected maps with different lengths to differ")
+		}
+
+		// Same length but different keys
+		b.Delete("key3")
+		b.Delete("key2")
+		b.Set("other", []int{2})
+		if Equal[string, []int](a, b, nil) {
+			t.Error("Expected maps with different keys to differ")
+		}
+
+		// Same keys with differing values
+		b.Delete("other")
+		b.Set("key2", []int{20})
+		if Equal[string, []int](a, b, nil) {
+			t.Error("Expected maps with different values to differ")
+		}
+
+		// A custom equality decides which values match
+		sameLen := func(x, y []int) bool { return len(x) == len(y) }
+		if !Equal(a, b, sameLen) {
+			t.Error("Expected maps to be equal under the custom equality")
+		}
+	})
+}
+
+func TestKeySetOperations(t *testing.T) {
+	sorted := func(keys []string) []string {
+		slices.Sort(keys)
+		return keys
+	}
+	tests := []struct {
+		name      string
+		a, b      []string
+		union     []string
+		intersect []string
+		diff      []string
+	}{
+		{"Disjoint", []string{"a", "b"}, []string{"c"}, []string{"a", "b", "c"}, nil, []string{"a", "b"}},
+		{"FullOverlap", []string{"a", "b"}, []string{"a", "b"}, []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"PartialOverlap", []string{"a", "b", "c"}, []string{"b", "c", "d"}, []string{"a", "b", "c", "d"}, []string{"b", "c"}, []string{"a"}},
+		{"Empty", nil, []string{"a"}, []string{"a"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachImpl(t, func(t *testing.T, a Map[string, int]) {
+				b := NewCASMap[string, int]()
+				for i, k := range tt.a {
+					a.Set(k, i)
+				}
+				for i, k := range tt.b {
+					b.Set(k, i)
+				}
+
+				if got := sorted(UnionKeys[string, int](a, b)); !slices.Equal(got, tt.union) {
+					t.Errorf("UnionKeys: expected %v, got %v", tt.union, got)
+				}
+				if got := sorted(IntersectKeys[string, int](a, b)); !slices.Equal(got, tt.intersect) {
+					t.Errorf("IntersectKeys: expected %v, got %v", tt.intersect, got)
+				}
+				if got := sorted(DifferenceKeys[string, int](a, b)); !slices.Equal(got, tt.diff) {
+					t.Errorf("DifferenceKeys: expected %v, got %v", tt.diff, got)
+				}
+
+				// Empty results are non-nil slices, consistently across the three operations
+				for op, got := range map[string][]string{
+					"UnionKeys":      UnionKeys[string, int](a, b),
+					"IntersectKeys":  IntersectKeys[string, int](a, b),
+					"DifferenceKeys": DifferenceKeys[string, int](a, b),
+				} {
+					if got == nil {
+						t.Errorf("%s: expected a non-nil slice, got nil", op)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestRangePaired(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, a Map[string, int]) {
+		a.Set("key1", 1)
+		a.Set("key2", 2)
+		a.Set("onlyA", 3)
+		b := NewRWMutexMap[string, string]()
+		b.Set("key1", "one")
+		b.Set("key2", "two")
+		b.Set("onlyB", "three")
+
+		got := make(map[string]string)
+		RangePaired[string, int, string](a, b, func(key string, x int, y string) bool {
+			got[key] = strconv.Itoa(x) + "=" + y
+			return true
+		})
+		expected := map[string]string{"key1": "1=one", "key2": "2=two"}
+		if !maps.Equal(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		// Early termination
+		calls := 0
+		RangePaired[string, int, string](a, b, func(string, int, string) bool {
+			calls++
+			return false
+		})
+		if calls != 1 {
+			t.Errorf("Expected iteration to stop after 1 call, got %d", calls)
+		}
+	})
+}
+
+func TestUpsertNested(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, map[string]int]) {
+		// Creates the nested map when the outer key is missing
+		UpsertNested(m, "user1", "clicks", 1)
+		inner, ok := m.Get("user1")
+		if !ok || inner["clicks"] != 1 {
+			t.Errorf("Expected clicks 1, got %v", inner)
+		}
+
+		// Keeps sibling keys and leaves the previous nested map untouched
+		UpsertNested(m, "user1", "views", 5)
+		updated, _ := m.Get("user1")
+		if updated["clicks"] != 1 || updated["views"] != 5 {
+			t.Errorf("Expected clicks 1 and views 5, got %v", updated)
+		}
+		if _, ok := inner["views"]; ok {
+			t.Error("Expected previously loaded nested map to be unaffected")
+		}
+	})
+}
+
+func TestUpsertNested_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	forEachImpl(t, func(t *testing.T, m Map[string, map[int]int]) {
+		const goroutines = 10
+		const iterations = 50
+
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					UpsertNested(m, "outer", id*iterations+j, j)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		inner, _ := m.Get("outer")
+		if len(inner) != goroutines*iterations {
+			t.Errorf("Expected %d nested entries, got %d", goroutines*iterations, len(inner))
+		}
+	})
+}
+
+func TestAppendValue(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, []int]) {
+		AppendValue(m, "key1", 1, 2)
+		before, _ := m.Get("key1")
+		AppendValue(m, "key1", 3)
+		AppendValue(m, "key1")
+
+		if got, _ := m.Get("key1"); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("Expected [1 2 3], got %v", got)
+		}
+		// A slice read before the append is unaffected
+		if !slices.Equal(before, []int{1, 2}) {
+			t.Errorf("Expected earlier read to stay [1 2], got %v", before)
+		}
+	})
+}
+
+func TestAppendValue_Concurrent(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, []int]) {
+		const goroutines = 10
+		const iterations = 50
+
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					AppendValue(m, "key1", id*iterations+j)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		got, _ := m.Get("key1")
+		slices.Sort(got)
+		if len(got) != goroutines*iterations {
+			t.Fatalf("Expected %d items, got %d", goroutines*iterations, len(got))
+		}
+		for i, v := range got {
+			if v != i {
+				t.Fatalf("Expected item %d at index %d, got %d", i, i, v)
+			}
+		}
+	})
+}
+
+func TestRemoveValue(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, []int]) {
+		m.Set("key1", []int{1, 2, 3, 2})
+		before, _ := m.Get("key1")
+
+		// Only the first match is removed
+		if !RemoveValue(m, "key1", 2) {
+			t.Error("Expected RemoveValue to remove a present element")
+		}
+		if got, _ := m.Get("key1"); !slices.Equal(got, []int{1, 3, 2}) {
+			t.Errorf("Expected [1 3 2], got %v", got)
+		}
+		// A slice read before the removal is unaffected
+		if !slices.Equal(before, []int{1, 2, 3, 2}) {
+			t.Errorf("Expected earlier read to stay [1 2 3 2], got %v", before)
+		}
+
+		// Absent element or key
+		if RemoveValue(m, "key1", 4) {
+			t.Error("Expected RemoveValue to fail for an absent element")
+		}
+		if RemoveValue(m, "missing", 1) {
+			t.Error("Expected RemoveValue to fail for a missing key")
+		}
+		if m.Has("missing") || m.Len() != 1 {
+			t.Errorf("Expected only key1, got %v", m.Keys())
+		}
+
+		// Removing the last element deletes the key
+		m.Set("key2", []int{5})
+		if !RemoveValue(m, "key2", 5) {
+			t.Error("Expected RemoveValue to remove the last element")
+		}
+		if m.Has("key2") || m.Len() != 1 {
+			t.Errorf("Expected key2 to be deleted, got %v", m.Keys())
+		}
+	})
+}
+
+func TestRange_WritesInCallback(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m Map[string, int]) {
+		m.Set("key1", 100)
+		m.Set("key2", 200)
+		m.Set("key3", 300)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.Range(func(key string, value int) bool {
+				m.Set(key+"-copy", value)
+				m.Delete(key)
+				return true
+			})
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Range deadlocked when writing from the callback")
+		}
+
+		if m.Len() != 3 {
+			t.Errorf("Expected length 3, got %d", m.Len())
+		}
+		for _, key := range []string{"key1", "key2", "key3"} {
+			if m.Has(key) {
+				t.Errorf("Expected %s to be deleted", key)
+			}
+			if !m.Has(key + "-copy") {
+				t.Errorf("Expected %s-copy to exist", key)
+			}
+		}
+	})
+}
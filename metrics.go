@@ -0,0 +1,21 @@
+package mapx
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeMetric writes a single sample in the Prometheus text exposition format,
+// preceded by its HELP and TYPE lines.
+func writeMetric(w io.Writer, name, help, typ string, value int64) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, typ, name, value)
+	return err
+}
+
+// metricName joins the metric prefix and name with an underscore.
+func metricName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
@@ -0,0 +1,194 @@
+package mapx
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ChangeOp identifies the kind of change reported by a ChangeEvent.
+type ChangeOp int
+
+const (
+	// ChangeSet reports that a key was added or its value replaced.
+	ChangeSet ChangeOp = iota + 1
+	// ChangeDelete reports that a key was removed.
+	ChangeDelete
+	// ChangeClear reports that all keys were removed at once. Operations that replace the whole
+	// contents (Replace, Reload, UnmarshalJSON, GobDecode) report a ChangeClear followed by a ChangeSet
+	// for every new entry.
+	ChangeClear
+)
+
+// String returns the name of the operation.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeSet:
+		return "set"
+	case ChangeDelete:
+		return "delete"
+	case ChangeClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// ChangeEvent describes a single change made to a map by a write operation.
+type ChangeEvent[K comparable, V any] struct {
+	Op       ChangeOp
+	Key      K    // zero for ChangeClear
+	OldValue V    // value before the change; zero if the key didn't exist
+	NewValue V    // value after the change; zero for ChangeDelete and ChangeClear
+	Existed  bool // whether the key existed before the change
+}
+
+// observer wraps a callback registered with OnChange so that it can be identified on removal.
+type observer[K comparable, V any] struct {
+	f func(event ChangeEvent[K, V])
+}
+
+// observers holds the callbacks registered with OnChange.
+// The list is copied on registration, so writers load it with a single atomic read.
+type observers[K comparable, V any] struct {
+	mu   sync.Mutex // serializes registration and removal
+	list atomic.Pointer[[]*observer[K, V]]
+}
+
+// add registers f and returns a function that removes it again.
+func (o *observers[K, V]) add(f func(event ChangeEvent[K, V])) (unregister func()) {
+	obs := &observer[K, V]{f: f}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var oldList []*observer[K, V]
+	if p := o.list.Load(); p != nil {
+		oldList = *p
+	}
+	newList := make([]*observer[K, V], len(oldList), len(oldList)+1)
+	copy(newList, oldList)
+	newList = append(newList, obs)
+	o.list.Store(&newList)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.remove(obs)
+		})
+	}
+}
+
+// remove unregisters obs.
+func (o *observers[K, V]) remove(obs *observer[K, V]) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	oldList := *o.list.Load()
+	newList := make([]*observer[K, V], 0, len(oldList))
+	for _, other := range oldList {
+		if other != obs {
+			newList = append(newList, other)
+		}
+	}
+	o.list.Store(&newList)
+}
+
+// subscribe registers a callback that forwards events to a new channel with the given buffer size,
+// dropping events the channel has no room for, and returns the channel and a function that
+// unregisters the callback and closes the channel.
+func (o *observers[K, V]) subscribe(buffer int) (<-chan ChangeEvent[K, V], func()) {
+	ch := make(chan ChangeEvent[K, V], buffer)
+	var mu sync.RWMutex // held for writing while closing, so that no send races with close
+	closed := false
+	unregister := o.add(func(event ChangeEvent[K, V]) {
+		mu.RLock()
+		defer mu.RUnlock()
+		if closed {
+			// A write that began before unsubscribing may still deliver its events
+			return
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	})
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			unregister()
+			mu.Lock()
+			defer mu.Unlock()
+			closed = true
+			close(ch)
+		})
+	}
+}
+
+// begin starts collecting the changes of a write operation for the currently registered callbacks.
+// If none are registered, the returned changeSet records nothing, so writes don't allocate.
+func (o *observers[K, V]) begin() changeSet[K, V] {
+	var c changeSet[K, V]
+	if p := o.list.Load(); p != nil {
+		c.observers = *p
+	}
+	return c
+}
+
+// changeSet collects the events of a single write operation so that they can be delivered after
+// the new snapshot has been stored and any lock has been released.
+type changeSet[K comparable, V any] struct {
+	observers []*observer[K, V]
+	events    []ChangeEvent[K, V]
+}
+
+// active reports whether any callback is registered, i.e. whether events are being recorded.
+func (c *changeSet[K, V]) active() bool {
+	return len(c.observers) > 0
+}
+
+// set records that key was set to newValue.
+func (c *changeSet[K, V]) set(key K, oldValue V, existed bool, newValue V) {
+	if !c.active() {
+		return
+	}
+	c.events = append(c.events, ChangeEvent[K, V]{Op: ChangeSet, Key: key, OldValue: oldValue, NewValue: newValue, Existed: existed})
+}
+
+// delete records that key was removed.
+func (c *changeSet[K, V]) delete(key K, oldValue V) {
+	if !c.active() {
+		return
+	}
+	c.events = append(c.events, ChangeEvent[K, V]{Op: ChangeDelete, Key: key, OldValue: oldValue, Existed: true})
+}
+
+// clear records that all keys were removed.
+func (c *changeSet[K, V]) clear() {
+	if !c.active() {
+		return
+	}
+	c.events = append(c.events, ChangeEvent[K, V]{Op: ChangeClear})
+}
+
+// replace records that the whole contents were replaced by data.
+func (c *changeSet[K, V]) replace(data map[K]V) {
+	if !c.active() {
+		return
+	}
+	c.clear()
+	for k, v := range data {
+		c.events = append(c.events, ChangeEvent[K, V]{Op: ChangeSet, Key: k, NewValue: v})
+	}
+}
+
+// discard drops the events recorded so far, for a CAS attempt that failed and will be retried.
+func (c *changeSet[K, V]) discard() {
+	c.events = c.events[:0]
+}
+
+// flush delivers the recorded events to every callback, in order, on the calling goroutine.
+func (c *changeSet[K, V]) flush() {
+	for _, event := range c.events {
+		for _, obs := range c.observers {
+			obs.f(event)
+		}
+	}
+}
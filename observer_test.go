@@ -0,0 +1,221 @@
+package mapx
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// observable is implemented by the maps that support OnChange.
+type observable[K comparable, V any] interface {
+	Map[K, V]
+	OnChange(f func(event ChangeEvent[K, V])) (unregister func())
+	Subscribe(buffer int) (<-chan ChangeEvent[K, V], func())
+	GetAndDelete(key K) (V, bool)
+	Merge(other map[K]V)
+}
+
+func TestOnChange_SetAndDelete(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[string, int]) {
+		var events []ChangeEvent[string, int]
+		m.OnChange(func(event ChangeEvent[string, int]) {
+			events = append(events, event)
+		})
+
+		m.Set("key1", 100)
+		m.Set("key1", 200)
+		m.Delete("key1")
+		// Writes that change nothing don't report events
+		m.Delete("missing")
+		m.SetIfAbsent("key2", 1)
+		m.SetIfAbsent("key2", 2)
+		m.GetAndDelete("key2")
+		// GetOrSet reports a zero OldValue when it inserts, and nothing when the key exists
+		m.GetOrSet("key3", 5)
+		m.GetOrSet("key3", 6)
+
+		expected := []ChangeEvent[string, int]{
+			{Op: ChangeSet, Key: "key1", NewValue: 100},
+			{Op: ChangeSet, Key: "key1", OldValue: 100, NewValue: 200, Existed: true},
+			{Op: ChangeDelete, Key: "key1", OldValue: 200, Existed: true},
+			{Op: ChangeSet, Key: "key2", NewValue: 1},
+			{Op: ChangeDelete, Key: "key2", OldValue: 1, Existed: true},
+			{Op: ChangeSet, Key: "key3", NewValue: 5},
+		}
+		if len(events) != len(expected) {
+			t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+		}
+		for i := range expected {
+			if events[i] != expected[i] {
+				t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], events[i])
+			}
+		}
+	})
+}
+
+func TestOnChange_BatchAndClear(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[string, int]) {
+		m.Set("key1", 1)
+
+		ops := map[ChangeOp]int{}
+		m.OnChange(func(event ChangeEvent[string, int]) {
+			ops[event.Op]++
+		})
+
+		m.Merge(map[string]int{"key1": 10, "key2": 20, "key3": 30})
+		Increment[string, int](m, "key1", 1)
+		m.Clear()
+
+		if ops[ChangeSet] != 4 || ops[ChangeClear] != 1 || ops[ChangeDelete] != 0 {
+			t.Errorf("Expected 4 sets and 1 clear, got %v", ops)
+		}
+	})
+}
+
+func TestOnChange_MultipleAndUnregister(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[string, int]) {
+		var first, second int
+		unregister := m.OnChange(func(ChangeEvent[string, int]) { first++ })
+		m.OnChange(func(ChangeEvent[string, int]) { second++ })
+
+		m.Set("key1", 1)
+		unregister()
+		unregister() // calling it again is a no-op
+		m.Set("key2", 2)
+
+		if first != 1 || second != 2 {
+			t.Errorf("Expected callbacks to be called (1, 2) times, got (%d, %d)", first, second)
+		}
+	})
+}
+
+func TestOnChange_CallbackMayWrite(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[string, int]) {
+		// Callbacks run outside any lock, so writing from them must not deadlock
+		m.OnChange(func(event ChangeEvent[string, int]) {
+			if event.Op == ChangeSet && event.Key == "source" {
+				m.Set("mirror", event.NewValue)
+			}
+		})
+
+		m.Set("source", 42)
+		if val, _ := m.Get("mirror"); val != 42 {
+			t.Errorf("Expected mirror=42, got %d", val)
+		}
+	})
+}
+
+func TestOnChange_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	forEachImpl(t, func(t *testing.T, m observable[int, int]) {
+		var sets atomic.Int64
+		m.OnChange(func(event ChangeEvent[int, int]) {
+			if event.Op == ChangeSet {
+				sets.Add(1)
+			}
+		})
+
+		const goroutines = 10
+		const iterations = 100
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					m.Set(id*iterations+j, j)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		// Failed CAS attempts must not report events
+		if n := sets.Load(); n != goroutines*iterations {
+			t.Errorf("Expected %d set events, got %d", goroutines*iterations, n)
+		}
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[string, int]) {
+		events, unsubscribe := m.Subscribe(8)
+
+		m.Set("key1", 100)
+		m.Set("key1", 200)
+		m.Delete("key1")
+
+		expected := []ChangeEvent[string, int]{
+			{Op: ChangeSet, Key: "key1", NewValue: 100},
+			{Op: ChangeSet, Key: "key1", OldValue: 100, NewValue: 200, Existed: true},
+			{Op: ChangeDelete, Key: "key1", OldValue: 200, Existed: true},
+		}
+		for i := range expected {
+			if event := <-events; event != expected[i] {
+				t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], event)
+			}
+		}
+
+		// Unsubscribing stops delivery and closes the channel
+		unsubscribe()
+		unsubscribe() // calling it again is a no-op
+		m.Set("key2", 1)
+		if event, ok := <-events; ok {
+			t.Errorf("Expected a closed channel after unsubscribe, got %+v", event)
+		}
+	})
+}
+
+func TestSubscribe_DropsWhenFull(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[int, int]) {
+		events, unsubscribe := m.Subscribe(2)
+		defer unsubscribe()
+
+		// Nobody is receiving, so writes must not block and only the first events fit
+		for i := 0; i < 10; i++ {
+			m.Set(i, i)
+		}
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 buffered events, got %d", len(events))
+		}
+		if first, second := <-events, <-events; first.Key != 0 || second.Key != 1 {
+			t.Errorf("Expected the events of keys 0 and 1, got %d and %d", first.Key, second.Key)
+		}
+	})
+}
+
+func TestSubscribe_UnsubscribeConcurrent(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m observable[int, int]) {
+		events, unsubscribe := m.Subscribe(16)
+		received := make(chan int)
+		go func() {
+			n := 0
+			for range events {
+				n++
+			}
+			received <- n
+		}()
+
+		// Unsubscribing while writers are sending must neither panic nor block them
+		const goroutines = 10
+		const iterations = 100
+		var wg sync.WaitGroup
+		wg.Add(goroutines)
+		for i := 0; i < goroutines; i++ {
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					m.Set(id*iterations+j, j)
+				}
+			}(i)
+		}
+		unsubscribe()
+		wg.Wait()
+
+		if n := <-received; n > goroutines*iterations {
+			t.Errorf("Expected at most %d events, got %d", goroutines*iterations, n)
+		}
+	})
+}
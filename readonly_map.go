@@ -0,0 +1,67 @@
+package mapx
+
+// ReadOnlyMap is an immutable view of a snapshot of a map, returned by Freeze.
+//
+// It has no write methods, and since the snapshot it holds is never modified, writes to the map it
+// was frozen from don't affect it. Reads are lock-free and, as there are no writes to race with,
+// never observe a change. The zero value is an empty map.
+type ReadOnlyMap[K comparable, V any] struct {
+	data  map[K]V
+	clone func(v V) V // the CASMap's clone function, applied to values returned by reads
+}
+
+// Get retrieves the value associated with the given key.
+// Returns the zero value and false if the key doesn't exist; otherwise returns the value and true.
+func (m ReadOnlyMap[K, V]) Get(key K) (V, bool) {
+	value, ok := m.data[key]
+	if ok && m.clone != nil {
+		value = m.clone(value)
+	}
+	return value, ok
+}
+
+// Has checks whether the given key exists in the map.
+func (m ReadOnlyMap[K, V]) Has(key K) bool {
+	_, ok := m.data[key]
+	return ok
+}
+
+// Len returns the number of key-value pairs in the map.
+func (m ReadOnlyMap[K, V]) Len() int {
+	return len(m.data)
+}
+
+// Range calls f for each key-value pair, stopping iteration if f returns false.
+func (m ReadOnlyMap[K, V]) Range(f func(key K, value V) bool) {
+	for k, v := range m.data {
+		if !f(k, m.cloneValue(v)) {
+			break
+		}
+	}
+}
+
+// Keys returns a slice containing all keys in the map.
+func (m ReadOnlyMap[K, V]) Keys() []K {
+	keys := make([]K, 0, len(m.data))
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Values returns a slice containing all values in the map.
+func (m ReadOnlyMap[K, V]) Values() []V {
+	values := make([]V, 0, len(m.data))
+	for _, v := range m.data {
+		values = append(values, m.cloneValue(v))
+	}
+	return values
+}
+
+// cloneValue applies the clone function to v if one was set.
+func (m ReadOnlyMap[K, V]) cloneValue(v V) V {
+	if m.clone != nil {
+		return m.clone(v)
+	}
+	return v
+}
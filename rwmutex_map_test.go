@@ -1,8 +1,20 @@
 package mapx
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestRWMutexMap_BasicOperations(t *testing.T) {
@@ -45,153 +57,2182 @@ func TestRWMutexMap_BasicOperations(t *testing.T) {
 	m.Delete("nonexistent")
 }
 
+func TestRWMutexMap_Swap(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	// Swapping a missing key stores the value and reports not loaded
+	if prev, loaded := m.Swap("key1", 100); loaded || prev != 0 {
+		t.Errorf("Expected (0, false), got (%d, %v)", prev, loaded)
+	}
+
+	// Swapping an existing key returns the previous value
+	if prev, loaded := m.Swap("key1", 200); !loaded || prev != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", prev, loaded)
+	}
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+}
+
+func TestRWMutexMap_SwapConcurrentCreate(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	const goroutines = 16
+
+	// Exactly one of the concurrent Swaps creates the key; all others report an update
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			if _, loaded := m.Swap("key1", id); !loaded {
+				created.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := created.Load(); n != 1 {
+		t.Errorf("Expected exactly one Swap to create the key, got %d", n)
+	}
+}
+
+func TestRWMutexMap_GetAndDelete(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	if val, ok := m.GetAndDelete("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+
+	// Second call finds nothing
+	if val, ok := m.GetAndDelete("key1"); ok || val != 0 {
+		t.Errorf("Expected (0, false), got (%d, %v)", val, ok)
+	}
+}
+
+func TestRWMutexMap_GetAndDeleteConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewRWMutexMap[int, int]()
+	const keys = 1000
+	const goroutines = 8
+	for i := 0; i < keys; i++ {
+		m.Set(i, i)
+	}
+
+	var deleted [keys]atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for k := 0; k < keys; k++ {
+				if _, ok := m.GetAndDelete(k); ok {
+					deleted[k].Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for k := range deleted {
+		if n := deleted[k].Load(); n != 1 {
+			t.Errorf("Expected key %d to be deleted exactly once, got %d", k, n)
+		}
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0, got %d", m.Len())
+	}
+}
+
+func TestRWMutexMap_Rename(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("tmp", 100)
+	m.Set("other", 200)
+
+	if !m.Rename("tmp", "final") {
+		t.Error("Expected Rename of an existing key to succeed")
+	}
+	if m.Has("tmp") {
+		t.Error("Expected tmp to be removed")
+	}
+	if v, _ := m.Get("final"); v != 100 {
+		t.Errorf("Expected final=100, got %d", v)
+	}
+	if m.Rename("missing", "final") {
+		t.Error("Expected Rename of a missing key to fail")
+	}
+
+	// Rename overwrites, RenameIfAbsent doesn't
+	if m.RenameIfAbsent("other", "final") {
+		t.Error("Expected RenameIfAbsent onto an existing key to fail")
+	}
+	if v, _ := m.Get("other"); v != 200 {
+		t.Errorf("Expected other to be unchanged, got %d", v)
+	}
+	if !m.Rename("other", "final") {
+		t.Error("Expected Rename onto an existing key to succeed")
+	}
+	if v, _ := m.Get("final"); v != 200 || m.Len() != 1 {
+		t.Errorf("Expected only final=200, got %v", m.Keys())
+	}
+	if !m.RenameIfAbsent("final", "tmp") {
+		t.Error("Expected RenameIfAbsent onto a missing key to succeed")
+	}
+
+	// Renaming a key to itself changes nothing
+	if !m.Rename("tmp", "tmp") {
+		t.Error("Expected Rename of a key to itself to succeed")
+	}
+	if v, _ := m.Get("tmp"); v != 200 || m.Len() != 1 {
+		t.Errorf("Expected only tmp=200, got %v", m.Keys())
+	}
+}
+
+func TestRWMutexMap_RenameConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewRWMutexMap[int, int]()
+	const slots = 4
+	const goroutines = 8
+	const iterations = 500
+	m.Set(0, 42)
+
+	// A single value moves between the slots; it must never be lost or duplicated
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			found := 0
+			m.Range(func(key, value int) bool {
+				if value != 42 {
+					t.Errorf("Unexpected value %d at %d", value, key)
+				}
+				found++
+				return true
+			})
+			if found != 1 {
+				t.Errorf("Expected exactly one key holding the value, got %d", found)
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				from := (id + j) % slots
+				m.Rename(from, (from+1)%slots)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	if m.Len() != 1 {
+		t.Errorf("Expected exactly one key, got %v", m.Keys())
+	}
+}
+
+func TestRWMutexMap_SwapKeys(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("active", 1)
+	m.Set("standby", 2)
+
+	if !m.SwapKeys("active", "standby") {
+		t.Error("Expected SwapKeys of existing keys to succeed")
+	}
+	active, _ := m.Get("active")
+	standby, _ := m.Get("standby")
+	if active != 2 || standby != 1 {
+		t.Errorf("Expected active=2 and standby=1, got %d and %d", active, standby)
+	}
+
+	if m.SwapKeys("active", "missing") || m.SwapKeys("missing", "active") {
+		t.Error("Expected SwapKeys with a missing key to fail")
+	}
+	if m.Has("missing") || m.Len() != 2 {
+		t.Errorf("Expected no insertion, got %v", m.Keys())
+	}
+	if !m.SwapKeys("active", "active") {
+		t.Error("Expected SwapKeys of a key with itself to succeed")
+	}
+	if v, _ := m.Get("active"); v != 2 {
+		t.Errorf("Expected active to be unchanged, got %d", v)
+	}
+
+	// A concurrent reader never observes both keys holding the same buffer
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			snapshot := m.Snapshot()
+			if snapshot["active"]+snapshot["standby"] != 3 || snapshot["active"] == snapshot["standby"] {
+				t.Errorf("Observed an intermediate state: %v", snapshot)
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		m.SwapKeys("active", "standby")
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRWMutexMap_Pop(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	// Pop on empty map should fail
+	if key, val, ok := m.Pop(); ok {
+		t.Errorf("Expected (\"\", 0, false), got (%q, %d, true)", key, val)
+	}
+
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	seen := map[string]int{}
+	for m.Len() > 0 {
+		key, val, ok := m.Pop()
+		if !ok {
+			t.Fatal("Expected Pop to succeed on non-empty map")
+		}
+		seen[key] = val
+	}
+	if len(seen) != 2 || seen["key1"] != 100 || seen["key2"] != 200 {
+		t.Errorf("Expected both entries to be popped, got %v", seen)
+	}
+}
+
+func TestRWMutexMap_PopConcurrent(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	const entries = 1000
+	const goroutines = 10
+	for i := 0; i < entries; i++ {
+		m.Set(i, i)
+	}
+
+	var mu sync.Mutex
+	popped := make(map[int]int, entries)
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				key, _, ok := m.Pop()
+				if !ok {
+					return
+				}
+				mu.Lock()
+				popped[key]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(popped) != entries {
+		t.Errorf("Expected %d distinct keys popped, got %d", entries, len(popped))
+	}
+	for key, n := range popped {
+		if n != 1 {
+			t.Errorf("Key %d was popped %d times", key, n)
+		}
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected map to be drained, got length %d", m.Len())
+	}
+}
+
+func TestRWMutexMap_GetOr(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	if v := m.GetOr("key1", -1); v != 100 {
+		t.Errorf("Expected 100, got %d", v)
+	}
+	if v := m.GetOr("missing", -1); v != -1 {
+		t.Errorf("Expected default -1, got %d", v)
+	}
+	if m.Has("missing") {
+		t.Error("Expected GetOr not to insert the default")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		m.GetOr("key1", -1)
+		m.GetOr("missing", -1)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected GetOr not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestRWMutexMap_GetMulti(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	// Missing keys are omitted
+	got := m.GetMulti("key1", "key2", "missing")
+	if len(got) != 2 || got["key1"] != 100 || got["key2"] != 200 {
+		t.Errorf("Expected key1 and key2, got %v", got)
+	}
+
+	none := m.GetMulti("missing")
+	if none == nil || len(none) != 0 {
+		t.Errorf("Expected an empty non-nil map, got %v", none)
+	}
+}
+
+func TestRWMutexMap_GetMultiConsistent(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	keys := make([]int, 10)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	// The writer stores every key with the same generation in one update, so a consistent
+	// read must never observe two different generations
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for gen := 0; ; gen++ {
+			batch := make(map[int]int, len(keys))
+			for _, key := range keys {
+				batch[key] = gen
+			}
+			m.Merge(batch)
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		got := m.GetMulti(keys...)
+		if len(got) == 0 {
+			continue
+		}
+		if len(got) != len(keys) {
+			t.Fatalf("Observed partial batch: %d of %d keys present", len(got), len(keys))
+		}
+		for key, gen := range got {
+			if gen != got[0] {
+				t.Fatalf("Observed mixed generations: key %d has %d, key 0 has %d", key, gen, got[0])
+			}
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRWMutexMap_UpdateMulti(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	m.UpdateMulti([]string{"key1", "key3"}, func(key string, old int, exists bool) int {
+		if !exists {
+			return -1
+		}
+		return old + 1
+	})
+
+	for key, want := range map[string]int{"key1": 101, "key2": 200, "key3": -1} {
+		if v, ok := m.Get(key); !ok || v != want {
+			t.Errorf("Expected %s=%d, got (%d, %v)", key, want, v, ok)
+		}
+	}
+}
+
+func TestRWMutexMap_UpdateMultiAtomic(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	keys := make([]int, 10)
+	for i := range keys {
+		keys[i] = i
+		m.Set(i, 0)
+	}
+
+	// Every update moves all keys to the same new generation, so a snapshot must never mix two
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			got := m.GetMulti(keys...)
+			for key, gen := range got {
+				if gen != got[0] {
+					t.Errorf("Observed mixed generations: key %d has %d, key 0 has %d", key, gen, got[0])
+					return
+				}
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		m.UpdateMulti(keys, func(_ int, old int, _ bool) int { return old + 1 })
+	}
+	close(done)
+	wg.Wait()
+
+	if v, _ := m.Get(9); v != 1000 {
+		t.Errorf("Expected 1000, got %d", v)
+	}
+}
+
+func TestRWMutexMap_DeleteMulti(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	// Missing and duplicate keys are not counted
+	if removed := m.DeleteMulti("key1", "key2", "key2", "missing"); removed != 2 {
+		t.Errorf("Expected 2 keys removed, got %d", removed)
+	}
+	if m.Has("key1") || m.Has("key2") || !m.Has("key3") {
+		t.Errorf("Expected only key3 to remain, got %v", m.Keys())
+	}
+
+	// Deleting only missing keys must not store a new snapshot
+	before := m.data.Load()
+	if removed := m.DeleteMulti("key1", "missing"); removed != 0 {
+		t.Errorf("Expected 0 keys removed, got %d", removed)
+	}
+	if m.data.Load() != before {
+		t.Error("Expected no copy when none of the keys exist")
+	}
+}
+
+func TestRWMutexMap_DeleteMultiAtomic(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+		m.Set(i, i)
+	}
+	m.Set(-1, -1)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			// Each snapshot must contain either all targeted keys or none of them
+			data := m.load()
+			present := 0
+			for _, key := range keys {
+				if _, ok := data[key]; ok {
+					present++
+				}
+			}
+			if present != 0 && present != len(keys) {
+				t.Errorf("Observed partial removal: %d of %d keys present", present, len(keys))
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+
+	if removed := m.DeleteMulti(keys...); removed != len(keys) {
+		t.Errorf("Expected %d keys removed, got %d", len(keys), removed)
+	}
+	close(done)
+	wg.Wait()
+
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1, got %d", m.Len())
+	}
+}
+
+func TestRWMutexMap_DeleteWhere(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	before := m.data.Load()
+	oldData := m.load()
+	removed := m.DeleteWhere(func(key string, value int) bool { return value >= 200 })
+	if removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+	if !m.Has("key1") || m.Has("key2") || m.Has("key3") {
+		t.Errorf("Expected only key1 to remain, got %v", m.Keys())
+	}
+
+	// The removal is published as a single new snapshot
+	after := m.data.Load()
+	if after == before {
+		t.Error("Expected a new snapshot to be stored")
+	}
+	if len(oldData) != 3 {
+		t.Error("Expected the previous snapshot to be left untouched")
+	}
+
+	// Nothing matching must not store a new snapshot
+	if removed := m.DeleteWhere(func(key string, value int) bool { return false }); removed != 0 {
+		t.Errorf("Expected 0 entries removed, got %d", removed)
+	}
+	if m.data.Load() != after {
+		t.Error("Expected no copy when nothing matches")
+	}
+}
+
 func TestRWMutexMap_GetOrSet(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
 
-	// First call should set the value
-	val, existed := m.GetOrSet("key1", 100)
-	if existed || val != 100 {
-		t.Errorf("Expected (100, false), got (%d, %v)", val, existed)
+	// First call should set the value
+	val, existed := m.GetOrSet("key1", 100)
+	if existed || val != 100 {
+		t.Errorf("Expected (100, false), got (%d, %v)", val, existed)
+	}
+
+	// Second call should return existing value
+	val, existed = m.GetOrSet("key1", 200)
+	if !existed || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, existed)
+	}
+}
+
+func TestRWMutexMap_GetOrCompute(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	calls := 0
+	compute := func() int {
+		calls++
+		return 100
+	}
+
+	// First call should compute and set the value
+	val, existed := m.GetOrCompute("key1", compute)
+	if existed || val != 100 {
+		t.Errorf("Expected (100, false), got (%d, %v)", val, existed)
+	}
+
+	// Second call should return existing value without computing
+	val, existed = m.GetOrCompute("key1", compute)
+	if !existed || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, existed)
+	}
+	if calls != 1 {
+		t.Errorf("Expected f to be called once, got %d", calls)
+	}
+}
+
+func TestRWMutexMap_GetOrComputeConcurrent(t *testing.T) {
+	m := NewRWMutexMap[string, int64]()
+	const goroutines = 50
+
+	var calls atomic.Int64
+	results := make([]int64, goroutines)
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			results[id], _ = m.GetOrCompute("key", func() int64 {
+				return calls.Add(1)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	// All callers must observe the single stored value
+	stored, _ := m.Get("key")
+	for i, v := range results {
+		if v != stored {
+			t.Errorf("Goroutine %d got %d, expected stored value %d", i, v, stored)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected f to be called once under the lock, got %d", n)
+	}
+}
+
+func TestRWMutexMap_GetOrSetFunc(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	errOpen := errors.New("open failed")
+
+	// A failed initializer must leave the key absent
+	val, existed, err := m.GetOrSetFunc("key1", func() (int, error) {
+		return 1, errOpen
+	})
+	if !errors.Is(err, errOpen) || existed || val != 0 {
+		t.Errorf("Expected (0, false, %v), got (%d, %v, %v)", errOpen, val, existed, err)
+	}
+	if m.Has("key1") || m.Len() != 0 {
+		t.Errorf("Expected key1 to be absent after a failed initializer, got %v", m.Keys())
+	}
+
+	// The next call retries and stores the value on success
+	val, existed, err = m.GetOrSetFunc("key1", func() (int, error) {
+		return 100, nil
+	})
+	if err != nil || existed || val != 100 {
+		t.Errorf("Expected (100, false, nil), got (%d, %v, %v)", val, existed, err)
+	}
+
+	// An existing key is returned without calling f
+	val, existed, err = m.GetOrSetFunc("key1", func() (int, error) {
+		t.Error("Expected f not to be called for an existing key")
+		return 0, errOpen
+	})
+	if err != nil || !existed || val != 100 {
+		t.Errorf("Expected (100, true, nil), got (%d, %v, %v)", val, existed, err)
+	}
+}
+
+func TestRWMutexMap_SetIfAbsent(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	// Should set successfully
+	if !m.SetIfAbsent("key1", 100) {
+		t.Error("Expected SetIfAbsent to succeed")
+	}
+
+	// Should fail on second attempt
+	if m.SetIfAbsent("key1", 200) {
+		t.Error("Expected SetIfAbsent to fail")
+	}
+
+	// Value should remain unchanged
+	if val, _ := m.Get("key1"); val != 100 {
+		t.Errorf("Expected value 100, got %d", val)
+	}
+}
+
+func TestRWMutexMap_CompareAndSwap(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	// CAS on non-existent key should fail
+	if m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to fail on non-existent key")
+	}
+
+	m.Set("key1", 100)
+
+	// CAS with wrong old value should fail
+	if m.CompareAndSwap("key1", 999, 200) {
+		t.Error("Expected CAS to fail with wrong old value")
+	}
+
+	// CAS with correct old value should succeed
+	if !m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to succeed")
+	}
+
+	// Verify new value
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+}
+
+func TestRWMutexMap_Update(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	increment := func(old int, exists bool) int {
+		if !exists {
+			return 1
+		}
+		return old + 1
+	}
+
+	// Missing key starts from the callback's initial value
+	if val := m.Update("counter", increment); val != 1 {
+		t.Errorf("Expected 1, got %d", val)
+	}
+	if val := m.Update("counter", increment); val != 2 {
+		t.Errorf("Expected 2, got %d", val)
+	}
+	if val, _ := m.Get("counter"); val != 2 {
+		t.Errorf("Expected stored value 2, got %d", val)
+	}
+}
+
+func TestRWMutexMap_UpdateConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewRWMutexMap[string, int]()
+	const goroutines = 10
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				m.Update("counter", func(old int, _ bool) int { return old + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := m.Get("counter"); val != goroutines*iterations {
+		t.Errorf("Expected %d, got %d", goroutines*iterations, val)
+	}
+}
+
+func TestRWMutexMap_CompareAndSwapFunc(t *testing.T) {
+	type versioned struct {
+		Version int
+		Data    string
+	}
+	m := NewRWMutexMap[string, versioned]()
+
+	// Only move the version forward
+	setIfNewer := func(next versioned) bool {
+		return m.CompareAndSwapFunc("doc", func(current versioned, exists bool) (versioned, bool) {
+			return next, !exists || current.Version < next.Version
+		})
+	}
+
+	if !setIfNewer(versioned{Version: 1, Data: "a"}) {
+		t.Error("Expected swap to succeed for a missing key")
+	}
+	if !setIfNewer(versioned{Version: 3, Data: "c"}) {
+		t.Error("Expected swap to succeed for a newer version")
+	}
+	if setIfNewer(versioned{Version: 2, Data: "b"}) {
+		t.Error("Expected swap to fail for an older version")
+	}
+	if v, _ := m.Get("doc"); v.Version != 3 || v.Data != "c" {
+		t.Errorf("Expected version 3, got %+v", v)
+	}
+
+	// Concurrent writers racing with increasing versions must leave the highest one
+	var wg sync.WaitGroup
+	for i := 4; i <= 50; i++ {
+		wg.Add(1)
+		go func(version int) {
+			defer wg.Done()
+			setIfNewer(versioned{Version: version})
+		}(i)
+	}
+	wg.Wait()
+	if v, _ := m.Get("doc"); v.Version != 50 {
+		t.Errorf("Expected version 50, got %d", v.Version)
+	}
+}
+
+func TestRWMutexMap_ReplaceIf(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	positive := func(current int) bool { return current > 0 }
+
+	// Predicate passes
+	if prev, ok := m.ReplaceIf("key1", -1, positive); !ok || prev != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", prev, ok)
+	}
+	if val, _ := m.Get("key1"); val != -1 {
+		t.Errorf("Expected -1, got %d", val)
+	}
+
+	// Predicate fails: the current value is still returned
+	if prev, ok := m.ReplaceIf("key1", 200, positive); ok || prev != -1 {
+		t.Errorf("Expected (-1, false), got (%d, %v)", prev, ok)
+	}
+	if val, _ := m.Get("key1"); val != -1 {
+		t.Errorf("Expected -1 to be kept, got %d", val)
+	}
+
+	// Missing key: cond isn't called and nothing is inserted
+	prev, ok := m.ReplaceIf("missing", 300, func(int) bool {
+		t.Error("Expected cond not to be called for a missing key")
+		return true
+	})
+	if ok || prev != 0 || m.Has("missing") {
+		t.Errorf("Expected (0, false) and no insertion, got (%d, %v)", prev, ok)
+	}
+}
+
+func TestRWMutexMap_CompareAndSwapNonComparable(t *testing.T) {
+	m := NewRWMutexMap[string, []byte]()
+	m.Set("key1", []byte("old"))
+
+	// Slices can't be compared with ==, this used to panic
+	if m.CompareAndSwap("key1", []byte("other"), []byte("new")) {
+		t.Error("Expected CAS to fail with different slice contents")
+	}
+	if !m.CompareAndSwap("key1", []byte("old"), []byte("new")) {
+		t.Error("Expected CAS to succeed with equal slice contents")
+	}
+	if val, _ := m.Get("key1"); string(val) != "new" {
+		t.Errorf("Expected value new, got %s", val)
+	}
+
+	// Interface values holding non-comparable dynamic types
+	a := NewRWMutexMap[string, any]()
+	a.Set("key1", []int{1, 2})
+	if !a.CompareAndSwap("key1", []int{1, 2}, 3) {
+		t.Error("Expected CAS to succeed with equal dynamic slice")
+	}
+	if !a.CompareAndDelete("key1", 3) {
+		t.Error("Expected CompareAndDelete to succeed with comparable dynamic value")
+	}
+}
+
+func TestRWMutexMap_WithEqual(t *testing.T) {
+	type event struct {
+		ID   int
+		Seen time.Time
+	}
+	// Compare by ID only, ignoring the timestamp
+	m := NewRWMutexMapWithEqual[string](func(a, b event) bool { return a.ID == b.ID })
+	m.Set("key1", event{ID: 1, Seen: time.Now()})
+
+	if !m.CompareAndSwap("key1", event{ID: 1}, event{ID: 2}) {
+		t.Error("Expected CAS to succeed using the custom equality")
+	}
+	if m.CompareAndDelete("key1", event{ID: 1}) {
+		t.Error("Expected CompareAndDelete to fail using the custom equality")
+	}
+	if !m.CompareAndDelete("key1", event{ID: 2}) {
+		t.Error("Expected CompareAndDelete to succeed using the custom equality")
+	}
+
+	// Clones keep the equality function
+	m.Set("key2", event{ID: 3, Seen: time.Now()})
+	if !m.Clone().CompareAndSwap("key2", event{ID: 3}, event{ID: 4}) {
+		t.Error("Expected clone to use the custom equality")
+	}
+}
+
+func TestRWMutexMap_SkipUnchanged(t *testing.T) {
+	m := NewRWMutexMapSkipUnchanged[string, int](nil)
+	var events []ChangeEvent[string, int]
+	m.OnChange(func(e ChangeEvent[string, int]) {
+		events = append(events, e)
+	})
+	writes := 0
+	m.OnWrite(func(string, int, time.Duration) {
+		writes++
+	})
+
+	m.Set("key1", 100)
+	before := m.Snapshot()
+	m.Set("key1", 100)
+	if len(events) != 1 || writes != 1 {
+		t.Errorf("Expected the repeated Set to be skipped, got %d events and %d writes", len(events), writes)
+	}
+	if after := m.Snapshot(); !maps.Equal(before, after) {
+		t.Errorf("Expected contents to be unchanged, got %v", after)
+	}
+
+	// A different value is still stored
+	m.Set("key1", 200)
+	if v, _ := m.Get("key1"); v != 200 || len(events) != 2 || writes != 2 {
+		t.Errorf("Expected 200 after 2 events and writes, got %d after %d events and %d writes", v, len(events), writes)
+	}
+
+	// The custom equality decides what counts as unchanged, and clones keep the setting
+	type event struct {
+		ID   int
+		Seen time.Time
+	}
+	e := NewRWMutexMapSkipUnchanged[string](func(a, b event) bool { return a.ID == b.ID })
+	first := time.Unix(1, 0)
+	e.Set("key1", event{ID: 1, Seen: first})
+	e.Set("key1", event{ID: 1, Seen: first.Add(time.Second)})
+	if v, _ := e.Get("key1"); !v.Seen.Equal(first) {
+		t.Errorf("Expected the equal value to be skipped, got %v", v.Seen)
+	}
+	c := e.Clone()
+	c.Set("key1", event{ID: 1})
+	if v, _ := c.Get("key1"); !v.Seen.Equal(first) {
+		t.Errorf("Expected clone to skip equal values, got %v", v.Seen)
+	}
+}
+
+func TestRWMutexMap_CompareAndDelete(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+
+	// Delete on non-existent key should fail
+	if m.CompareAndDelete("key1", 100) {
+		t.Error("Expected CompareAndDelete to fail on non-existent key")
+	}
+
+	m.Set("key1", 100)
+
+	// Delete with wrong old value should fail and keep the entry
+	if m.CompareAndDelete("key1", 999) {
+		t.Error("Expected CompareAndDelete to fail with wrong old value")
+	}
+	if !m.Has("key1") {
+		t.Error("Expected key1 to still exist")
+	}
+
+	// Delete with correct old value should succeed
+	if !m.CompareAndDelete("key1", 100) {
+		t.Error("Expected CompareAndDelete to succeed")
+	}
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+}
+
+func TestRWMutexMap_CompareAndDeleteChangedValue(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	// Read the value, then let another goroutine replace it before deleting
+	seen, _ := m.Get("key1")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Set("key1", 200)
+	}()
+	<-done
+
+	if m.CompareAndDelete("key1", seen) {
+		t.Error("Expected CompareAndDelete to fail after the value changed")
+	}
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected newer value 200 to be kept, got %d", val)
+	}
+}
+
+func TestRWMutexMap_Clear(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	m.Clear()
+
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+	if m.Has("key1") {
+		t.Error("Expected all keys to be cleared")
+	}
+}
+
+func TestRWMutexMap_DeleteWhereShrinks(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	data := make(map[int]int, 100000)
+	for i := 0; i < 100000; i++ {
+		data[i] = i
+	}
+	m.Merge(data)
+	data = nil
+
+	before := liveHeap()
+	removed := m.DeleteWhere(func(key, _ int) bool {
+		return key%1000 != 0
+	})
+	after := liveHeap()
+
+	if removed != 99900 || m.Len() != 100 {
+		t.Fatalf("Expected 99900 entries removed and 100 left, got %d and %d", removed, m.Len())
+	}
+	for i := 0; i < 100000; i += 1000 {
+		if v, ok := m.Get(i); !ok || v != i {
+			t.Errorf("Expected %d=%d to remain, got %d, %v", i, i, v, ok)
+		}
+	}
+	// The remaining entries are copied into a small map, so the storage of the large one is released
+	if after > before/2 {
+		t.Errorf("Expected the removal to release most of the heap, went from %d to %d bytes", before, after)
+	}
+	runtime.KeepAlive(m)
+}
+
+func TestRWMutexMap_Compact(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	data := make(map[int]int, 10000)
+	for i := 0; i < 10000; i++ {
+		data[i] = i * 10
+	}
+	m.Merge(data)
+	m.DeleteWhere(func(key, _ int) bool {
+		return key%100 != 0
+	})
+
+	m.Compact()
+
+	if m.Len() != 100 {
+		t.Fatalf("Expected length 100 after compact, got %d", m.Len())
+	}
+	for i := 0; i < 10000; i += 100 {
+		if v, ok := m.Get(i); !ok || v != i*10 {
+			t.Errorf("Expected %d=%d after compact, got %d, %v", i, i*10, v, ok)
+		}
+	}
+
+	// The compacted map stays writable
+	m.Set(1, 10)
+	if v, _ := m.Get(1); v != 10 || m.Len() != 101 {
+		t.Errorf("Expected 1=10 and length 101, got %d and %d", v, m.Len())
+	}
+}
+
+func TestRWMutexMap_Reload(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	source := map[string]int{"key2": 200, "key3": 300}
+	if err := m.Reload(func() (map[string]int, error) { return source, nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.Has("key1") || m.Len() != 2 {
+		t.Errorf("Expected contents to be replaced, got %v", m.Keys())
+	}
+
+	// Later changes to the source must not leak into the map
+	source["key4"] = 400
+	if m.Has("key4") {
+		t.Error("Expected map to be independent of the provider's map")
+	}
+
+	// A failing provider leaves the map unchanged
+	errLoad := errors.New("load failed")
+	if err := m.Reload(func() (map[string]int, error) { return nil, errLoad }); err != errLoad {
+		t.Errorf("Expected %v, got %v", errLoad, err)
+	}
+	if val, _ := m.Get("key2"); val != 200 || m.Len() != 2 {
+		t.Error("Expected map to be unchanged after failed reload")
+	}
+}
+
+func TestRWMutexMap_Replace(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	newData := map[string]int{"key2": 200, "key3": 300}
+	old := m.Replace(newData)
+	if len(old) != 1 || old["key1"] != 100 {
+		t.Errorf("Expected previous contents {key1:100}, got %v", old)
+	}
+	if m.Has("key1") || m.Len() != 2 {
+		t.Errorf("Expected contents to be replaced, got %v", m.Keys())
+	}
+
+	// Neither the argument nor the result are shared with the map
+	newData["key4"] = 400
+	if m.Has("key4") {
+		t.Error("Expected map to be independent of the replacement map")
+	}
+	clone := m.Clone()
+	old = m.Replace(nil)
+	old["key5"] = 500
+	if clone.Has("key5") || clone.Len() != 2 {
+		t.Error("Expected returned map not to alias a shared snapshot")
+	}
+}
+
+func TestRWMutexMap_SwapMap(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	old := m.SwapMap(map[string]int{"key2": 200, "key3": 300})
+	if len(old) != 1 || old["key1"] != 100 {
+		t.Errorf("Expected previous contents {key1:100}, got %v", old)
+	}
+	if m.Has("key1") || m.Len() != 2 {
+		t.Errorf("Expected contents to be replaced, got %v", m.Keys())
+	}
+	if v, _ := m.Get("key3"); v != 300 {
+		t.Errorf("Expected key3=300, got %d", v)
+	}
+
+	// The returned map is the previous snapshot, so a frozen view taken before sees the same contents
+	frozen := m.Freeze()
+	if old := m.SwapMap(nil); len(old) != frozen.Len() || old["key2"] != 200 {
+		t.Errorf("Expected the previous snapshot, got %v", old)
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected a nil map to install an empty map, got %v", m.Keys())
+	}
+
+	// Readers observe either all of the old contents or all of the new ones
+	first := map[string]int{"a": 1, "b": 1}
+	m.SwapMap(first)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			snapshot := m.Snapshot()
+			if len(snapshot) != 2 || snapshot["a"] != snapshot["b"] {
+				t.Errorf("Observed a partially installed map: %v", snapshot)
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	for i := 2; i < 1000; i++ {
+		m.SwapMap(map[string]int{"a": i, "b": i})
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRWMutexMap_ReplaceNeverEmpty(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	first := map[int]int{1: 1, 2: 2, 3: 3}
+	second := map[int]int{4: 4, 5: 5, 6: 6}
+	m.Replace(first)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			// Readers must see either contents in full, never an intermediate state
+			if n := m.Len(); n != 3 {
+				t.Errorf("Observed intermediate state with %d entries", n)
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			m.Replace(second)
+		} else {
+			m.Replace(first)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRWMutexMap_Merge(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	other := map[string]int{"key2": 250, "key3": 300}
+	m.Merge(other)
+	if m.Len() != 3 {
+		t.Errorf("Expected length 3, got %d", m.Len())
+	}
+	for key, expected := range map[string]int{"key1": 100, "key2": 250, "key3": 300} {
+		if val, _ := m.Get(key); val != expected {
+			t.Errorf("Expected %s=%d, got %d", key, expected, val)
+		}
+	}
+
+	// Later changes to other must not leak into the map
+	other["key4"] = 400
+	if m.Has("key4") {
+		t.Error("Expected map to be independent of the merged map")
+	}
+
+	// Merging nothing leaves the snapshot untouched
+	before := m.data.Load()
+	m.Merge(nil)
+	if m.data.Load() != before {
+		t.Error("Expected empty merge not to store a new snapshot")
+	}
+}
+
+func TestRWMutexMap_MergeFunc(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	var conflicts []string
+	m.MergeFunc(map[string]int{"key2": 50, "key3": 300}, func(key string, oldValue, newValue int) int {
+		conflicts = append(conflicts, key)
+		return oldValue + newValue
+	})
+	if len(conflicts) != 1 || conflicts[0] != "key2" {
+		t.Errorf("Expected resolve to be called for [key2], got %v", conflicts)
+	}
+	if val, _ := m.Get("key2"); val != 250 {
+		t.Errorf("Expected resolved value 250, got %d", val)
+	}
+	if val, _ := m.Get("key3"); val != 300 {
+		t.Errorf("Expected new key to be stored as-is, got %d", val)
+	}
+}
+
+func TestRWMutexMap_MergePolicies(t *testing.T) {
+	src := map[string]int{"key1": 50, "key2": 250, "key3": 300}
+	tests := []struct {
+		name     string
+		policy   func(key string, oldValue, newValue int) int
+		expected map[string]int
+	}{
+		{"Overwrite", MergeOverwrite[string, int], map[string]int{"key1": 50, "key2": 250, "key3": 300, "key4": 400}},
+		{"SkipExisting", MergeSkipExisting[string, int], map[string]int{"key1": 100, "key2": 200, "key3": 300, "key4": 400}},
+		{"KeepMax", MergeKeepMax[string, int], map[string]int{"key1": 100, "key2": 250, "key3": 300, "key4": 400}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewRWMutexMap[string, int]()
+			m.Set("key1", 100)
+			m.Set("key2", 200)
+			m.Set("key4", 400)
+
+			m.MergeFunc(src, tt.policy)
+			if got := m.Snapshot(); !maps.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Type parameters are inferred when a policy is passed directly
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.MergeFunc(src, MergeSkipExisting)
+	if v, _ := m.Get("key1"); v != 100 || m.Len() != 3 {
+		t.Errorf("Expected key1=100 and 3 entries, got %v", m.Snapshot())
+	}
+}
+
+func TestRWMutexMap_MergeCopiesOnce(t *testing.T) {
+	base := NewRWMutexMap[int, int]()
+	for i := 0; i < 1000; i++ {
+		base.Set(i, i)
+	}
+	other := make(map[int]int, 100)
+	for i := 1000; i < 1100; i++ {
+		other[i] = i
+	}
+
+	// Merging N entries must cost no more allocations than the single copy made by one Set
+	setAllocs := testing.AllocsPerRun(10, func() {
+		base.Clone().Set(-1, -1)
+	})
+	mergeAllocs := testing.AllocsPerRun(10, func() {
+		base.Clone().Merge(other)
+	})
+	if mergeAllocs > setAllocs {
+		t.Errorf("Expected Merge to copy the map once (%v allocs), got %v allocs", setAllocs, mergeAllocs)
+	}
+}
+
+func TestRWMutexMap_Keys(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	keys := m.Keys()
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 keys, got %d", len(keys))
+	}
+
+	keyMap := make(map[string]bool)
+	for _, k := range keys {
+		keyMap[k] = true
+	}
+	if !keyMap["key1"] || !keyMap["key2"] || !keyMap["key3"] {
+		t.Error("Keys not returned correctly")
+	}
+}
+
+func TestRWMutexMap_Values(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	values := m.Values()
+	if len(values) != 3 {
+		t.Errorf("Expected 3 values, got %d", len(values))
+	}
+
+	valueMap := make(map[int]bool)
+	for _, v := range values {
+		valueMap[v] = true
+	}
+	if !valueMap[100] || !valueMap[200] || !valueMap[300] {
+		t.Error("Values not returned correctly")
+	}
+}
+
+func TestRWMutexMap_Range(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	count := 0
+	sum := 0
+	m.Range(func(key string, value int) bool {
+		count++
+		sum += value
+		return true
+	})
+
+	if count != 3 {
+		t.Errorf("Expected to visit 3 entries, visited %d", count)
+	}
+	if sum != 600 {
+		t.Errorf("Expected sum 600, got %d", sum)
+	}
+
+	// Test early termination
+	count = 0
+	m.Range(func(key string, value int) bool {
+		count++
+		return false // stop after first entry
+	})
+	if count != 1 {
+		t.Errorf("Expected to visit 1 entry, visited %d", count)
+	}
+}
+
+func TestRWMutexMap_RangeIndexed(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	last := -1
+	seen := make(map[string]bool)
+	m.RangeIndexed(func(i int, key string, value int) bool {
+		if i != last+1 {
+			t.Errorf("Expected index %d, got %d", last+1, i)
+		}
+		last = i
+		seen[key] = true
+		return true
+	})
+	if last != m.Len()-1 || len(seen) != 3 {
+		t.Errorf("Expected the last index to be %d over 3 keys, got %d over %d", m.Len()-1, last, len(seen))
+	}
+
+	// Test early termination
+	count := 0
+	m.RangeIndexed(func(i int, key string, value int) bool {
+		count++
+		return i < 1
+	})
+	if count != 2 {
+		t.Errorf("Expected to stop after 2 entries, visited %d", count)
+	}
+}
+
+func TestRWMutexMap_Snapshot(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	snap := m.Snapshot()
+	if len(snap) != 2 || snap["key1"] != 100 || snap["key2"] != 200 {
+		t.Errorf("Expected snapshot of all entries, got %v", snap)
+	}
+
+	// Mutating the snapshot must not affect the map, and vice versa
+	snap["key3"] = 300
+	delete(snap, "key1")
+	if m.Has("key3") || !m.Has("key1") {
+		t.Error("Expected map to be unaffected by snapshot mutation")
+	}
+	m.Set("key2", 999)
+	if snap["key2"] != 200 {
+		t.Errorf("Expected snapshot value 200, got %d", snap["key2"])
+	}
+}
+
+func TestRWMutexMap_CopyInto(t *testing.T) {
+	a := NewRWMutexMap[string, int]()
+	a.Set("key1", 100)
+	a.Set("key2", 200)
+	b := NewRWMutexMap[string, int]()
+	b.Set("key2", 201)
+	b.Set("key3", 300)
+
+	dst := map[string]int{"key1": 1, "unrelated": 42}
+	a.CopyInto(dst)
+	b.CopyInto(dst)
+
+	expected := map[string]int{"key1": 100, "key2": 201, "key3": 300, "unrelated": 42}
+	if !maps.Equal(dst, expected) {
+		t.Errorf("Expected %v, got %v", expected, dst)
+	}
+
+	// dst is owned by the caller and doesn't affect the map
+	dst["key1"] = 0
+	if v, _ := a.Get("key1"); v != 100 {
+		t.Errorf("Expected key1=100 to be unaffected, got %d", v)
+	}
+}
+
+func TestRWMutexMap_Filter(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	all := m.Filter(func(key string, value int) bool { return true })
+	if len(all) != 3 {
+		t.Errorf("Expected all 3 entries, got %v", all)
+	}
+
+	none := m.Filter(func(key string, value int) bool { return false })
+	if none == nil || len(none) != 0 {
+		t.Errorf("Expected an empty non-nil map, got %v", none)
+	}
+
+	some := m.Filter(func(key string, value int) bool { return value >= 200 })
+	if len(some) != 2 || some["key2"] != 200 || some["key3"] != 300 {
+		t.Errorf("Expected key2 and key3, got %v", some)
+	}
+
+	// The result is owned by the caller and doesn't affect the map
+	some["key4"] = 400
+	if m.Has("key4") || m.Len() != 3 {
+		t.Error("Expected map to be unaffected by changes to the filtered result")
+	}
+}
+
+func TestRWMutexMap_Items(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	if keys, values := m.Items(); len(keys) != 0 || len(values) != 0 {
+		t.Errorf("Expected no items, got %v %v", keys, values)
+	}
+
+	expected := map[string]int{"key1": 100, "key2": 200, "key3": 300}
+	for k, v := range expected {
+		m.Set(k, v)
+	}
+
+	keys, values := m.Items()
+	if len(keys) != len(expected) || len(values) != len(expected) {
+		t.Fatalf("Expected %d items, got %v %v", len(expected), keys, values)
+	}
+	for i, key := range keys {
+		if values[i] != expected[key] {
+			t.Errorf("Expected values[%d] to be %d for key %s, got %d", i, expected[key], key, values[i])
+		}
+	}
+}
+
+func TestRWMutexMap_Entries(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	if entries := m.Entries(); entries == nil || len(entries) != 0 {
+		t.Errorf("Expected an empty non-nil slice, got %v", entries)
+	}
+
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	entries := m.Entries()
+	slices.SortFunc(entries, func(a, b Entry[string, int]) int { return strings.Compare(a.Key, b.Key) })
+	expected := []Entry[string, int]{{"key1", 100}, {"key2", 200}}
+	if !slices.Equal(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+
+	// The result is owned by the caller and doesn't affect the map
+	entries[0].Value = 999
+	if v, _ := m.Get("key1"); v != 100 {
+		t.Errorf("Expected map to be unaffected by changes to the entries, got %d", v)
+	}
+
+	// Later writes don't affect the returned entries
+	m.Set("key2", 300)
+	if entries[1].Value != 200 {
+		t.Errorf("Expected entries to be unaffected by later writes, got %v", entries)
+	}
+}
+
+func TestRWMutexMap_Drain(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	if drained := m.Drain(); drained == nil || len(drained) != 0 {
+		t.Errorf("Expected an empty non-nil map, got %v", drained)
+	}
+
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	c := m.Clone()
+
+	drained := m.Drain()
+	if len(drained) != 2 || drained["key1"] != 100 || drained["key2"] != 200 {
+		t.Errorf("Expected key1 and key2, got %v", drained)
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected map to be empty after Drain, got %v", m.Keys())
+	}
+
+	// The drained map is a copy and doesn't alias a clone's snapshot
+	drained["key1"] = 999
+	if v, _ := c.Get("key1"); v != 100 {
+		t.Errorf("Expected clone to be unaffected by changes to the drained map, got %d", v)
+	}
+}
+
+func TestRWMutexMap_DrainConcurrent(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	const writers, perWriter = 4, 500
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				m.Set(w*perWriter+i, i)
+			}
+		}(w)
+	}
+
+	// Every key must be returned by exactly one Drain or remain in the map
+	seen := make(map[int]int)
+	collect := func(data map[int]int) {
+		for k := range data {
+			seen[k]++
+		}
+	}
+	writersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(writersDone)
+	}()
+	for draining := true; draining; {
+		select {
+		case <-writersDone:
+			draining = false
+		default:
+		}
+		collect(m.Drain())
+	}
+	collect(m.Snapshot())
+
+	if len(seen) != writers*perWriter {
+		t.Errorf("Expected %d keys, got %d", writers*perWriter, len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %d was returned %d times", k, n)
+		}
+	}
+}
+
+func TestRWMutexMap_Partition(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	for i := 1; i <= 10; i++ {
+		m.Set(fmt.Sprintf("key%d", i), i)
+	}
+
+	matched, rest := m.Partition(func(key string, value int) bool { return value%3 == 0 })
+	if len(matched) != 3 || len(rest) != 7 {
+		t.Errorf("Expected 3 matched and 7 other entries, got %v and %v", matched, rest)
+	}
+	for k := range matched {
+		if _, ok := rest[k]; ok {
+			t.Errorf("Expected %s to be in only one partition", k)
+		}
+	}
+	union := maps.Clone(matched)
+	maps.Copy(union, rest)
+	if !maps.Equal(union, m.Snapshot()) {
+		t.Errorf("Expected the partitions to add up to the map, got %v", union)
+	}
+
+	// Both results are non-nil even when one side is empty
+	all, none := m.Partition(func(key string, value int) bool { return true })
+	if len(all) != 10 || none == nil || len(none) != 0 {
+		t.Errorf("Expected all entries matched and an empty non-nil rest, got %v and %v", all, none)
+	}
+}
+
+func TestRWMutexMap_KeysWhere(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("user:1", 100)
+	m.Set("user:2", 200)
+	m.Set("group:1", 300)
+
+	users := m.KeysWhere(func(key string, value int) bool { return strings.HasPrefix(key, "user:") })
+	slices.Sort(users)
+	if !slices.Equal(users, []string{"user:1", "user:2"}) {
+		t.Errorf("Expected [user:1 user:2], got %v", users)
+	}
+
+	large := m.KeysWhere(func(key string, value int) bool { return value > 250 })
+	if !slices.Equal(large, []string{"group:1"}) {
+		t.Errorf("Expected [group:1], got %v", large)
+	}
+
+	if none := m.KeysWhere(func(key string, value int) bool { return false }); len(none) != 0 {
+		t.Errorf("Expected no keys, got %v", none)
+	}
+}
+
+func TestRWMutexMap_CountWhere(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("user:1", 100)
+	m.Set("user:2", 200)
+	m.Set("group:1", 300)
+
+	if n := m.CountWhere(func(key string, value int) bool { return true }); n != 3 {
+		t.Errorf("Expected all 3 entries to match, got %d", n)
+	}
+	if n := m.CountWhere(func(key string, value int) bool { return false }); n != 0 {
+		t.Errorf("Expected no entries to match, got %d", n)
+	}
+	if n := m.CountWhere(func(key string, value int) bool { return strings.HasPrefix(key, "user:") }); n != 2 {
+		t.Errorf("Expected 2 users, got %d", n)
+	}
+	if n := NewRWMutexMap[string, int]().CountWhere(func(key string, value int) bool { return true }); n != 0 {
+		t.Errorf("Expected 0 for an empty map, got %d", n)
+	}
+}
+
+func TestRWMutexMap_Diff(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("same", 1)
+	m.Set("changed", 20)
+	m.Set("added", 3)
+
+	old := map[string]int{"same": 1, "changed": 2, "removed": 4}
+	added, removed, changed := m.Diff(old, nil)
+	if len(added) != 1 || added[0] != "added" {
+		t.Errorf("Expected added [added], got %v", added)
+	}
+	if len(removed) != 1 || removed[0] != "removed" {
+		t.Errorf("Expected removed [removed], got %v", removed)
+	}
+	if len(changed) != 1 || changed[0] != "changed" {
+		t.Errorf("Expected changed [changed], got %v", changed)
+	}
+
+	// A custom equality decides which values count as changed
+	added, removed, changed = m.Diff(old, func(a, b int) bool { return true })
+	if len(added) != 1 || len(removed) != 1 || len(changed) != 0 {
+		t.Errorf("Expected no changed keys, got added=%v removed=%v changed=%v", added, removed, changed)
+	}
+
+	// Diffing against the map's own snapshot reports nothing
+	added, removed, changed = m.Diff(m.Snapshot(), nil)
+	if len(added)+len(removed)+len(changed) != 0 {
+		t.Errorf("Expected no differences, got added=%v removed=%v changed=%v", added, removed, changed)
+	}
+}
+
+func TestRWMutexMap_Freeze(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+
+	frozen := m.Freeze()
+
+	// Writes after Freeze don't affect the view
+	m.Set("key1", 101)
+	m.Set("key3", 300)
+	m.Delete("key2")
+	m.Clear()
+
+	if frozen.Len() != 2 {
+		t.Errorf("Expected frozen length 2, got %d", frozen.Len())
+	}
+	if v, ok := frozen.Get("key1"); !ok || v != 100 {
+		t.Errorf("Expected frozen key1=100, got %d, %v", v, ok)
+	}
+	if !frozen.Has("key2") || frozen.Has("key3") {
+		t.Error("Expected the frozen view to keep key2 and not see key3")
+	}
+	keys := frozen.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Errorf("Expected frozen keys [key1 key2], got %v", keys)
+	}
+	values := frozen.Values()
+	slices.Sort(values)
+	if !slices.Equal(values, []int{100, 200}) {
+		t.Errorf("Expected frozen values [100 200], got %v", values)
+	}
+	sum := 0
+	frozen.Range(func(key string, value int) bool {
+		sum += value
+		return true
+	})
+	if sum != 300 {
+		t.Errorf("Expected frozen sum 300, got %d", sum)
 	}
 
-	// Second call should return existing value
-	val, existed = m.GetOrSet("key1", 200)
-	if !existed || val != 100 {
-		t.Errorf("Expected (100, true), got (%d, %v)", val, existed)
+	// The zero value is an empty map
+	var empty ReadOnlyMap[string, int]
+	if empty.Len() != 0 || empty.Has("key1") {
+		t.Error("Expected the zero ReadOnlyMap to be empty")
 	}
 }
 
-func TestRWMutexMap_SetIfAbsent(t *testing.T) {
+func TestRWMutexMap_Clone(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
 
-	// Should set successfully
-	if !m.SetIfAbsent("key1", 100) {
-		t.Error("Expected SetIfAbsent to succeed")
+	c := m.Clone()
+	if c.Len() != 2 {
+		t.Errorf("Expected clone length 2, got %d", c.Len())
 	}
 
-	// Should fail on second attempt
-	if m.SetIfAbsent("key1", 200) {
-		t.Error("Expected SetIfAbsent to fail")
+	// Writes to the original must not affect the clone
+	m.Set("key1", 999)
+	m.Delete("key2")
+	if val, _ := c.Get("key1"); val != 100 {
+		t.Errorf("Expected clone value 100, got %d", val)
+	}
+	if !c.Has("key2") {
+		t.Error("Expected key2 to still exist in clone")
 	}
 
-	// Value should remain unchanged
-	if val, _ := m.Get("key1"); val != 100 {
-		t.Errorf("Expected value 100, got %d", val)
+	// Writes to the clone must not affect the original
+	c.Set("key3", 300)
+	if m.Has("key3") {
+		t.Error("Expected key3 to not exist in original")
 	}
 }
 
-func TestRWMutexMap_CompareAndSwap(t *testing.T) {
-	m := NewRWMutexMap[string, int]()
+func TestRWMutexMap_CloneIsConstantTime(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
 
-	// CAS on non-existent key should fail
-	if m.CompareAndSwap("key1", 100, 200) {
-		t.Error("Expected CAS to fail on non-existent key")
+	// Cloning shares the snapshot, so it only allocates the new map header
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Clone()
+	})
+	if allocs > 1 {
+		t.Errorf("Expected at most 1 allocation, got %v", allocs)
+	}
+}
+
+func TestRWMutexMap_ContainsValue(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	if m.ContainsValue(100, nil) {
+		t.Error("Expected empty map to contain no values")
 	}
 
 	m.Set("key1", 100)
+	m.Set("key2", 200)
 
-	// CAS with wrong old value should fail
-	if m.CompareAndSwap("key1", 999, 200) {
-		t.Error("Expected CAS to fail with wrong old value")
+	if !m.ContainsValue(200, nil) {
+		t.Error("Expected map to contain 200")
+	}
+	if m.ContainsValue(300, nil) {
+		t.Error("Expected map to not contain 300")
 	}
 
-	// CAS with correct old value should succeed
-	if !m.CompareAndSwap("key1", 100, 200) {
-		t.Error("Expected CAS to succeed")
+	// A custom eq is used instead of the map's equality
+	sameHundreds := func(a, b int) bool { return a/100 == b/100 }
+	if !m.ContainsValue(150, sameHundreds) {
+		t.Error("Expected 150 to match 100 with custom eq")
 	}
+	if m.ContainsValue(350, sameHundreds) {
+		t.Error("Expected 350 to match nothing with custom eq")
+	}
+}
 
-	// Verify new value
-	if val, _ := m.Get("key1"); val != 200 {
-		t.Errorf("Expected value 200, got %d", val)
+func TestRWMutexMap_ContainsValueNonComparable(t *testing.T) {
+	m := NewRWMutexMap[string, []int]()
+	m.Set("key1", []int{1, 2})
+
+	if !m.ContainsValue([]int{1, 2}, nil) {
+		t.Error("Expected map to contain [1 2]")
+	}
+	if m.ContainsValue([]int{3}, nil) {
+		t.Error("Expected map to not contain [3]")
 	}
 }
 
-func TestRWMutexMap_Clear(t *testing.T) {
+func TestRWMutexMap_MaxMinEntry(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	less := func(a, b int) bool { return a < b }
+
+	// Empty map should report not found
+	if _, _, ok := m.MaxEntry(less); ok {
+		t.Error("Expected MaxEntry to report empty map")
+	}
+	if _, _, ok := m.MinEntry(less); ok {
+		t.Error("Expected MinEntry to report empty map")
+	}
+
+	m.Set("key1", 200)
+	m.Set("key2", 300)
+	m.Set("key3", 100)
+
+	if key, val, ok := m.MaxEntry(less); !ok || key != "key2" || val != 300 {
+		t.Errorf("Expected (key2, 300, true), got (%s, %d, %v)", key, val, ok)
+	}
+	if key, val, ok := m.MinEntry(less); !ok || key != "key3" || val != 100 {
+		t.Errorf("Expected (key3, 100, true), got (%s, %d, %v)", key, val, ok)
+	}
+}
+
+func TestRWMutexMap_WriteMetrics(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
 	m.Set("key1", 100)
 	m.Set("key2", 200)
-	m.Set("key3", 300)
 
-	m.Clear()
+	var buf bytes.Buffer
+	if err := m.WriteMetrics(&buf, "cache"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 
-	if m.Len() != 0 {
-		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	expected := "# HELP cache_entries Number of entries in the map.\n" +
+		"# TYPE cache_entries gauge\n" +
+		"cache_entries 2\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
 	}
-	if m.Has("key1") {
-		t.Error("Expected all keys to be cleared")
+}
+
+func TestRWMutexMap_String(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key2", 200)
+	m.Set("key1", 100)
+	m.Set("key3", 300)
+
+	expected := "map[key1:100 key2:200 key3:300]"
+	if got := m.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := fmt.Sprintf("%v", m); got != expected {
+		t.Errorf("Expected %%v to print %q, got %q", expected, got)
+	}
+	if got := fmt.Sprintf("%+v", m); got != expected {
+		t.Errorf("Expected %%+v to print %q, got %q", expected, got)
+	}
+
+	// Keys that aren't ordered still format deterministically
+	type point struct{ x, y int }
+	p := NewRWMutexMap[point, string]()
+	p.Set(point{2, 1}, "b")
+	p.Set(point{1, 2}, "a")
+	if got := p.String(); got != "map[{1 2}:a {2 1}:b]" {
+		t.Errorf("Expected map[{1 2}:a {2 1}:b], got %q", got)
 	}
 }
 
-func TestRWMutexMap_Keys(t *testing.T) {
+func TestRWMutexMap_JSON(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
 	m.Set("key1", 100)
 	m.Set("key2", 200)
-	m.Set("key3", 300)
 
-	keys := m.Keys()
-	if len(keys) != 3 {
-		t.Errorf("Expected 3 keys, got %d", len(keys))
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != `{"key1":100,"key2":200}` {
+		t.Errorf("Unexpected JSON: %s", data)
 	}
 
-	keyMap := make(map[string]bool)
-	for _, k := range keys {
-		keyMap[k] = true
+	decoded := NewRWMutexMap[string, int]()
+	decoded.Set("stale", 1)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
 	}
-	if !keyMap["key1"] || !keyMap["key2"] || !keyMap["key3"] {
-		t.Error("Keys not returned correctly")
+	// Existing contents are replaced, not merged
+	if decoded.Has("stale") || decoded.Len() != 2 {
+		t.Errorf("Expected contents to be replaced, got %v", decoded.Keys())
+	}
+	if val, _ := decoded.Get("key2"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+
+	// Invalid JSON leaves the map unchanged
+	if err := json.Unmarshal([]byte(`{"key1":"oops"}`), decoded); err == nil {
+		t.Error("Expected error for invalid value type")
+	}
+	if val, _ := decoded.Get("key1"); val != 100 {
+		t.Errorf("Expected value 100, got %d", val)
 	}
 }
 
-func TestRWMutexMap_Values(t *testing.T) {
+func TestRWMutexMap_JSONStructValues(t *testing.T) {
+	type config struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	m := NewRWMutexMap[string, config]()
+	m.Set("primary", config{Host: "db1", Port: 5432})
+	m.Set("replica", config{Host: "db2", Port: 5433})
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded RWMutexMap[string, config]
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if val, _ := decoded.Get("replica"); val != (config{Host: "db2", Port: 5433}) {
+		t.Errorf("Expected replica config, got %+v", val)
+	}
+	if decoded.Len() != 2 {
+		t.Errorf("Expected length 2, got %d", decoded.Len())
+	}
+}
+
+func TestRWMutexMap_WriteJSON(t *testing.T) {
+	type item struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags,omitempty"`
+	}
+	strs := NewRWMutexMap[string, item]()
+	strs.Set("b<&>", item{Name: "quoted \"x\""})
+	strs.Set("a", item{Name: "a", Tags: []string{"t1", "t2"}})
+	strs.Set("ü", item{})
+
+	// Integer keys are sorted by their string form, as encoding/json does
+	ints := NewRWMutexMap[int, float64]()
+	for _, k := range []int{9, 10, -1, 100} {
+		ints.Set(k, float64(k)/4)
+	}
+
+	texts := NewRWMutexMap[textKey, bool]()
+	texts.Set(textKey{"x", 1}, true)
+	texts.Set(textKey{"a", 2}, false)
+
+	for name, m := range map[string]interface {
+		MarshalJSON() ([]byte, error)
+		WriteJSON(w io.Writer) error
+	}{
+		"string": strs, "int": ints, "text": texts, "empty": NewRWMutexMap[string, int](),
+	} {
+		expected, err := m.MarshalJSON()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		var buf bytes.Buffer
+		if err := m.WriteJSON(&buf); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if buf.String() != string(expected) {
+			t.Errorf("%s: expected %s, got %s", name, expected, buf.String())
+		}
+	}
+
+	// Unsupported keys fail like MarshalJSON, before anything is written
+	type point struct{ X, Y int }
+	points := NewRWMutexMap[point, int]()
+	points.Set(point{1, 2}, 100)
+	var buf bytes.Buffer
+	if err := points.WriteJSON(&buf); err == nil || buf.Len() != 0 {
+		t.Errorf("Expected an error and no output for an unsupported key type, got %v and %q", err, buf.String())
+	}
+}
+
+func TestRWMutexMap_JSONUnsupportedKey(t *testing.T) {
+	type point struct{ X, Y int }
+	m := NewRWMutexMap[point, int]()
+	m.Set(point{1, 2}, 100)
+
+	if _, err := json.Marshal(m); err == nil {
+		t.Error("Expected error for unsupported key type")
+	}
+}
+
+func TestRWMutexMap_Gob(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	for i := 0; i < 100; i++ {
+		m.Set(fmt.Sprintf("key%d", i), i)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded := NewRWMutexMap[string, int]()
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if decoded.Len() != m.Len() {
+		t.Errorf("Expected length %d, got %d", m.Len(), decoded.Len())
+	}
+	m.Range(func(key string, value int) bool {
+		if val, ok := decoded.Get(key); !ok || val != value {
+			t.Errorf("Expected (%d, true) for %s, got (%d, %v)", value, key, val, ok)
+		}
+		return true
+	})
+
+	// Corrupt input leaves the map unchanged
+	if err := decoded.GobDecode([]byte("garbage")); err == nil {
+		t.Error("Expected error for corrupt input")
+	}
+	if decoded.Len() != m.Len() {
+		t.Errorf("Expected length %d, got %d", m.Len(), decoded.Len())
+	}
+}
+
+func TestRWMutexMap_RangeWritesInCallback(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
 	m.Set("key1", 100)
 	m.Set("key2", 200)
 	m.Set("key3", 300)
 
-	values := m.Values()
-	if len(values) != 3 {
-		t.Errorf("Expected 3 values, got %d", len(values))
+	// Writes and nested Ranges inside the callback neither deadlock nor affect the keys being visited
+	seen := make(map[string]int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Range(func(key string, value int) bool {
+			seen[key] = value
+			m.Set(key+"-copy", value)
+			m.Delete("key3")
+			m.Range(func(string, int) bool { return true })
+			return true
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Range deadlocked when writing from the callback")
 	}
 
-	valueMap := make(map[int]bool)
-	for _, v := range values {
-		valueMap[v] = true
+	if len(seen) != 3 || seen["key1"] != 100 || seen["key2"] != 200 || seen["key3"] != 300 {
+		t.Errorf("Expected iteration over the original snapshot, got %v", seen)
 	}
-	if !valueMap[100] || !valueMap[200] || !valueMap[300] {
-		t.Error("Values not returned correctly")
+	if m.Has("key3") || m.Len() != 5 {
+		t.Errorf("Expected writes from the callback to be applied, got %v", m.Keys())
 	}
 }
 
-func TestRWMutexMap_Range(t *testing.T) {
+func TestRWMutexMap_RangeNoAllocs(t *testing.T) {
+	m := NewRWMutexMap[int, int]()
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	sum := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Range(func(key, value int) bool {
+			sum += value
+			return true
+		})
+	})
+	if allocs != 0 {
+		t.Errorf("Expected Range not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestRWMutexMap_All(t *testing.T) {
 	m := NewRWMutexMap[string, int]()
 	m.Set("key1", 100)
 	m.Set("key2", 200)
 	m.Set("key3", 300)
 
-	count := 0
 	sum := 0
-	m.Range(func(key string, value int) bool {
-		count++
-		sum += value
-		return true
-	})
-
-	if count != 3 {
-		t.Errorf("Expected to visit 3 entries, visited %d", count)
+	for _, v := range m.All() {
+		sum += v
 	}
 	if sum != 600 {
 		t.Errorf("Expected sum 600, got %d", sum)
 	}
 
-	// Test early termination
-	count = 0
-	m.Range(func(key string, value int) bool {
+	// Breaking early stops the iteration
+	count := 0
+	for range m.All() {
 		count++
-		return false // stop after first entry
-	})
+		break
+	}
 	if count != 1 {
 		t.Errorf("Expected to visit 1 entry, visited %d", count)
 	}
+
+	// Mutations during iteration don't change what is yielded
+	seen := make(map[string]int)
+	for k, v := range m.All() {
+		m.Set("extra-"+k, v)
+		m.Delete("key1")
+		seen[k] = v
+	}
+	if len(seen) != 3 || seen["key1"] != 100 {
+		t.Errorf("Expected the original 3 entries, got %v", seen)
+	}
+}
+
+func TestRWMutexMap_KeysValuesSeq(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	keys := make(map[string]bool)
+	for k := range m.KeysSeq() {
+		keys[k] = true
+	}
+	if len(keys) != 3 || !keys["key1"] || !keys["key2"] || !keys["key3"] {
+		t.Errorf("Expected all 3 keys, got %v", keys)
+	}
+
+	sum := 0
+	for v := range m.ValuesSeq() {
+		sum += v
+	}
+	if sum != 600 {
+		t.Errorf("Expected sum 600, got %d", sum)
+	}
+
+	// Breaking early stops the iteration
+	count := 0
+	for range m.KeysSeq() {
+		count++
+		break
+	}
+	for range m.ValuesSeq() {
+		count++
+		break
+	}
+	if count != 2 {
+		t.Errorf("Expected to visit 1 entry per iterator, visited %d", count)
+	}
 }
 
 func TestRWMutexMap_Concurrent(t *testing.T) {
@@ -237,6 +2278,76 @@ func TestRWMutexMap_Concurrent(t *testing.T) {
 	}
 }
 
+func TestRWMutexMap_SetIfPresent(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	m.Set("key1", 100)
+
+	if !m.SetIfPresent("key1", 200) {
+		t.Error("Expected SetIfPresent to succeed for an existing key")
+	}
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected 200, got %d", val)
+	}
+
+	// A missing key is neither inserted nor causes a copy
+	before := m.data.Load()
+	if m.SetIfPresent("missing", 300) {
+		t.Error("Expected SetIfPresent to fail for a missing key")
+	}
+	if m.Has("missing") {
+		t.Error("Expected SetIfPresent not to insert a missing key")
+	}
+	if m.data.Load() != before {
+		t.Error("Expected no copy when the key doesn't exist")
+	}
+}
+
+func TestRWMutexMap_Collect(t *testing.T) {
+	src := map[string]int{"key1": 100, "key2": 200, "key3": 300}
+	m := CollectRWMutexMap(maps.All(src))
+
+	if m.Len() != len(src) {
+		t.Errorf("Expected length %d, got %d", len(src), m.Len())
+	}
+	for k, v := range src {
+		if got, ok := m.Get(k); !ok || got != v {
+			t.Errorf("Expected (%d, true) for %s, got (%d, %v)", v, k, got, ok)
+		}
+	}
+
+	// The map is usable like any other and independent of the source
+	src["key4"] = 400
+	m.Set("key5", 500)
+	if m.Has("key4") || !m.Has("key5") {
+		t.Error("Expected map to be independent of the source")
+	}
+
+	if empty := CollectRWMutexMap(maps.All(map[string]int{})); empty.Len() != 0 {
+		t.Errorf("Expected an empty map, got %v", empty.Keys())
+	}
+}
+
+func TestRWMutexMap_FromMap(t *testing.T) {
+	src := map[string]int{"key1": 100, "key2": 200}
+	m := NewRWMutexMapFromMap(src)
+
+	if val, ok := m.Get("key2"); m.Len() != 2 || !ok || val != 200 {
+		t.Errorf("Expected the contents of src, got %v", m.Snapshot())
+	}
+
+	// The source is copied, not aliased
+	src["key3"] = 300
+	delete(src, "key1")
+	if m.Has("key3") || !m.Has("key1") {
+		t.Error("Expected map to be unaffected by changes to src")
+	}
+
+	m.Set("key4", 400)
+	if _, ok := src["key4"]; ok {
+		t.Error("Expected src to be unaffected by writes to the map")
+	}
+}
+
 func TestRWMutexMap_WithCapacity(t *testing.T) {
 	m := NewRWMutexMapWithCapacity[string, int](100)
 	m.Set("key1", 100)
@@ -245,3 +2356,41 @@ func TestRWMutexMap_WithCapacity(t *testing.T) {
 		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
 	}
 }
+
+func TestRWMutexMap_SyncMapAliases(t *testing.T) {
+	m := NewRWMutexMap[string, int]()
+	ref := NewRWMutexMap[string, int]()
+
+	m.Store("key1", 100)
+	ref.Set("key1", 100)
+	v, ok := m.Load("key1")
+	refV, refOK := ref.Get("key1")
+	if v != refV || ok != refOK || v != 100 {
+		t.Errorf("Expected Load to match Get, got %d, %v and %d, %v", v, ok, refV, refOK)
+	}
+	if v, ok := m.Load("missing"); ok || v != 0 {
+		t.Errorf("Expected Load of a missing key to return 0, false, got %d, %v", v, ok)
+	}
+
+	for _, tc := range []struct {
+		key   string
+		value int
+	}{{"key1", 1}, {"key2", 200}} {
+		actual, loaded := m.LoadOrStore(tc.key, tc.value)
+		refActual, refLoaded := ref.GetOrSet(tc.key, tc.value)
+		if actual != refActual || loaded != refLoaded {
+			t.Errorf("LoadOrStore(%q): expected %d, %v like GetOrSet, got %d, %v", tc.key, refActual, refLoaded, actual, loaded)
+		}
+	}
+
+	for _, key := range []string{"key1", "key1"} {
+		v, loaded := m.LoadAndDelete(key)
+		refV, refLoaded := ref.GetAndDelete(key)
+		if v != refV || loaded != refLoaded {
+			t.Errorf("LoadAndDelete(%q): expected %d, %v like GetAndDelete, got %d, %v", key, refV, refLoaded, v, loaded)
+		}
+	}
+	if m.Has("key1") || m.Len() != 1 {
+		t.Errorf("Expected only key2 to remain, got %v", m.Keys())
+	}
+}
@@ -0,0 +1,290 @@
+package mapx
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedMap is a concurrent-safe Map implementation that hashes keys into a fixed number of
+// independent RWMutexMap shards, so that a write only copies the shard holding the key.
+//
+// Reads are lock-free like in RWMutexMap. Writes lock and copy a single shard, which divides
+// the copy cost by the number of shards and lets writes to different shards proceed in parallel.
+//
+// Advantages:
+//   - Write cost scales with the shard size instead of the whole map
+//   - Writes to different shards don't contend with each other
+//   - Suitable for large maps that still see a non-trivial write rate
+//
+// Disadvantages:
+//   - Operations spanning all shards (Range, Keys, Values, Clear) are not atomic across shards
+//   - Hashing every key adds a small constant overhead to reads compared to RWMutexMap
+//   - Multi-key updates are only atomic per shard
+//   - Every write takes a shared lock so that Reshard can exclude writes while it moves entries
+type ShardedMap[K comparable, V any] struct {
+	layout atomic.Pointer[shardLayout[K, V]]
+	resize sync.RWMutex // held for reading by writes and for writing by Reshard
+	size   atomic.Int64 // number of entries across all shards, updated by the shards under their locks
+}
+
+// shardLayout is the set of shards of a ShardedMap and the hash seed that assigns keys to them.
+// Reshard replaces the whole layout at once, so a loaded layout is always internally consistent.
+type shardLayout[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards []*RWMutexMap[K, V]
+}
+
+// NewShardedMap creates a new ShardedMap instance with the given number of shards.
+// A shard count below 1 is treated as 1.
+func NewShardedMap[K comparable, V any](shards int) *ShardedMap[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+	l := &shardLayout[K, V]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*RWMutexMap[K, V], shards),
+	}
+	m := &ShardedMap[K, V]{}
+	for i := range l.shards {
+		l.shards[i] = m.newShard(make(map[K]V))
+	}
+	m.layout.Store(l)
+	return m
+}
+
+// newShard creates a shard holding data, which it takes ownership of, that keeps the map's entry
+// counter up to date. The entries of data are not counted: they are either none, or entries Reshard
+// moves from the old shards, which are already counted.
+func (m *ShardedMap[K, V]) newShard(data map[K]V) *RWMutexMap[K, V] {
+	s := &RWMutexMap[K, V]{size: &m.size}
+	s.data.Store(&data)
+	return s
+}
+
+// shardIndex returns the index of the shard responsible for key.
+func (l *shardLayout[K, V]) shardIndex(key K) int {
+	return int(maphash.Comparable(l.seed, key) % uint64(len(l.shards)))
+}
+
+// shard returns the shard responsible for key.
+func (l *shardLayout[K, V]) shard(key K) *RWMutexMap[K, V] {
+	return l.shards[l.shardIndex(key)]
+}
+
+// shard returns the shard responsible for key in the current layout.
+// Writes must hold resize for reading from before calling it until the shard has been written,
+// so that Reshard can't move the entries of the shard in between.
+func (m *ShardedMap[K, V]) shard(key K) *RWMutexMap[K, V] {
+	return m.layout.Load().shard(key)
+}
+
+// Reshard redistributes all entries over newShards shards, treating a count below 1 as 1.
+// It blocks writes while the entries are copied into the new shards, which are then installed in
+// a single atomic store. Reads are never blocked: until the new layout is installed they use the
+// old one, which holds the same entries because no write can happen meanwhile.
+// It is O(n), so it is meant for occasional adjustment when the load pattern changes.
+func (m *ShardedMap[K, V]) Reshard(newShards int) {
+	if newShards < 1 {
+		newShards = 1
+	}
+	m.resize.Lock()
+	defer m.resize.Unlock()
+	old := m.layout.Load()
+	l := &shardLayout[K, V]{
+		seed:   old.seed,
+		shards: make([]*RWMutexMap[K, V], newShards),
+	}
+	data := make([]map[K]V, newShards)
+	for i := range data {
+		data[i] = make(map[K]V)
+	}
+	for _, s := range old.shards {
+		for k, v := range s.load() {
+			data[l.shardIndex(k)][k] = v
+		}
+	}
+	for i := range l.shards {
+		l.shards[i] = m.newShard(data[i])
+	}
+	m.layout.Store(l)
+}
+
+// Get retrieves the value associated with the given key.
+// Returns the zero value and false if the key doesn't exist; otherwise returns the value and true.
+// Read operations are completely lock-free.
+func (m *ShardedMap[K, V]) Get(key K) (V, bool) {
+	return m.shard(key).Get(key)
+}
+
+// Set associates the given value with the given key.
+// If the key already exists, the old value will be overwritten.
+// Only the shard holding the key is locked and copied.
+func (m *ShardedMap[K, V]) Set(key K, value V) {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	m.shard(key).Swap(key, value)
+}
+
+// Delete removes the given key from the map.
+// Has no effect if the key doesn't exist.
+func (m *ShardedMap[K, V]) Delete(key K) {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	m.shard(key).GetAndDelete(key)
+}
+
+// Len returns the number of key-value pairs in the map.
+// It is a single atomic read of a counter maintained by every write, so it is O(1) regardless of
+// the number of shards. Each shard updates the counter while it still holds its lock, so the counter
+// never goes negative, but it may briefly lag writes to other shards that are still in progress.
+func (m *ShardedMap[K, V]) Len() int {
+	return int(m.size.Load())
+}
+
+// Has checks whether the given key exists in the map.
+func (m *ShardedMap[K, V]) Has(key K) bool {
+	return m.shard(key).Has(key)
+}
+
+// Clear removes all key-value pairs from the map, one shard at a time.
+func (m *ShardedMap[K, V]) Clear() {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	for _, s := range m.layout.Load().shards {
+		s.Clear()
+	}
+}
+
+// Range iterates over all key-value pairs in the map, shard by shard.
+// Calls f for each pair, stopping iteration if f returns false.
+// Note: the snapshots of all shards are loaded before iteration starts, so it's safe to call write
+// methods within f without deadlock and such writes are never observed by the current iteration.
+// The shard snapshots are loaded one after another, so they don't form a single atomic snapshot.
+func (m *ShardedMap[K, V]) Range(f func(key K, value V) bool) {
+	shards := m.layout.Load().shards
+	snapshots := make([]map[K]V, len(shards))
+	for i, s := range shards {
+		snapshots[i] = s.load()
+	}
+	for _, data := range snapshots {
+		for k, v := range data {
+			if !f(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// RangeParallel is like Range, but iterates the shards concurrently on up to workers goroutines,
+// each of which takes the next shard not yet iterated until none are left. A worker count below 1 is
+// treated as 1, and one above the number of shards as the number of shards. f is called concurrently
+// and must be safe for that. Returning false from f stops all workers after their current call.
+// RangeParallel returns once every worker has stopped.
+func (m *ShardedMap[K, V]) RangeParallel(workers int, f func(key K, value V) bool) {
+	shards := m.layout.Load().shards
+	snapshots := make([]map[K]V, len(shards))
+	for i, s := range shards {
+		snapshots[i] = s.load()
+	}
+	workers = min(max(workers, 1), len(snapshots))
+
+	var next atomic.Int64 // index of the next shard to iterate
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(snapshots) && !stopped.Load(); i = int(next.Add(1) - 1) {
+				for k, v := range snapshots[i] {
+					if stopped.Load() {
+						return
+					}
+					if !f(k, v) {
+						stopped.Store(true)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Keys returns a slice containing all keys in the map.
+func (m *ShardedMap[K, V]) Keys() []K {
+	keys := make([]K, 0, m.Len())
+	for _, s := range m.layout.Load().shards {
+		for k := range s.load() {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Values returns a slice containing all values in the map.
+func (m *ShardedMap[K, V]) Values() []V {
+	values := make([]V, 0, m.Len())
+	for _, s := range m.layout.Load().shards {
+		for _, v := range s.load() {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// GetOrSet retrieves the value for the given key, or sets it to the given value if it doesn't exist.
+// Returns the value and true if the key already existed; otherwise returns the new value and false.
+func (m *ShardedMap[K, V]) GetOrSet(key K, value V) (V, bool) {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	return m.shard(key).GetOrSet(key, value)
+}
+
+// SetIfAbsent sets the value for the given key only if it doesn't already exist.
+// Returns true if the value was set, false if the key already existed.
+func (m *ShardedMap[K, V]) SetIfAbsent(key K, value V) bool {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	return m.shard(key).SetIfAbsent(key, value)
+}
+
+// CompareAndSwap atomically compares and swaps: sets newValue only if current value equals oldValue.
+// Returns true if the swap succeeded, false if it failed (key doesn't exist or value doesn't match).
+func (m *ShardedMap[K, V]) CompareAndSwap(key K, oldValue, newValue V) bool {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	return m.shard(key).CompareAndSwap(key, oldValue, newValue)
+}
+
+// compute atomically replaces the values of keys with the ones returned by f.
+// Keys are grouped by shard and each group is applied as a single update of its shard,
+// so the batch is atomic per shard but not across shards.
+func (m *ShardedMap[K, V]) compute(keys []K, f func(key K, value V, exists bool) (V, bool)) bool {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	l := m.layout.Load()
+	if len(keys) == 1 {
+		return l.shard(keys[0]).compute(keys, f)
+	}
+	groups := make(map[int][]K)
+	for _, key := range keys {
+		i := l.shardIndex(key)
+		groups[i] = append(groups[i], key)
+	}
+	stored := false
+	for i, group := range groups {
+		if l.shards[i].compute(group, f) {
+			stored = true
+		}
+	}
+	return stored
+}
+
+// update atomically applies the op returned by f to key in its shard.
+func (m *ShardedMap[K, V]) update(key K, f func(value V, exists bool) (V, updateOp)) bool {
+	m.resize.RLock()
+	defer m.resize.RUnlock()
+	return m.shard(key).update(key, f)
+}
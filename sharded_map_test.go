@@ -0,0 +1,559 @@
+package mapx
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedMap_BasicOperations(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+
+	// Test Set and Get
+	m.Set("key1", 100)
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+
+	// Test Get non-existent key
+	if val, ok := m.Get("key2"); ok {
+		t.Errorf("Expected (0, false), got (%d, true)", val)
+	}
+
+	// Test Has
+	if !m.Has("key1") {
+		t.Error("Expected key1 to exist")
+	}
+	if m.Has("key2") {
+		t.Error("Expected key2 to not exist")
+	}
+
+	// Test Len
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1, got %d", m.Len())
+	}
+
+	// Test Delete
+	m.Delete("key1")
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0, got %d", m.Len())
+	}
+
+	// Test Delete non-existent key (should not panic)
+	m.Delete("nonexistent")
+}
+
+func TestShardedMap_GetOrSet(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+
+	// First call should set the value
+	val, existed := m.GetOrSet("key1", 100)
+	if existed || val != 100 {
+		t.Errorf("Expected (100, false), got (%d, %v)", val, existed)
+	}
+
+	// Second call should return existing value
+	val, existed = m.GetOrSet("key1", 200)
+	if !existed || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, existed)
+	}
+}
+
+func TestShardedMap_SetIfAbsent(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+
+	// Should set successfully
+	if !m.SetIfAbsent("key1", 100) {
+		t.Error("Expected SetIfAbsent to succeed")
+	}
+
+	// Should fail on second attempt
+	if m.SetIfAbsent("key1", 200) {
+		t.Error("Expected SetIfAbsent to fail")
+	}
+
+	// Value should remain unchanged
+	if val, _ := m.Get("key1"); val != 100 {
+		t.Errorf("Expected value 100, got %d", val)
+	}
+}
+
+func TestShardedMap_CompareAndSwap(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+
+	// CAS on non-existent key should fail
+	if m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to fail on non-existent key")
+	}
+
+	m.Set("key1", 100)
+
+	// CAS with wrong old value should fail
+	if m.CompareAndSwap("key1", 999, 200) {
+		t.Error("Expected CAS to fail with wrong old value")
+	}
+
+	// CAS with correct old value should succeed
+	if !m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to succeed")
+	}
+
+	// Verify new value
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+}
+
+func TestShardedMap_Clear(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	m.Clear()
+
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+	if m.Has("key1") {
+		t.Error("Expected all keys to be cleared")
+	}
+}
+
+func TestShardedMap_Keys(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	keys := m.Keys()
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 keys, got %d", len(keys))
+	}
+
+	keyMap := make(map[string]bool)
+	for _, k := range keys {
+		keyMap[k] = true
+	}
+	if !keyMap["key1"] || !keyMap["key2"] || !keyMap["key3"] {
+		t.Error("Keys not returned correctly")
+	}
+}
+
+func TestShardedMap_Values(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	values := m.Values()
+	if len(values) != 3 {
+		t.Errorf("Expected 3 values, got %d", len(values))
+	}
+
+	valueMap := make(map[int]bool)
+	for _, v := range values {
+		valueMap[v] = true
+	}
+	if !valueMap[100] || !valueMap[200] || !valueMap[300] {
+		t.Error("Values not returned correctly")
+	}
+}
+
+func TestShardedMap_Range(t *testing.T) {
+	m := NewShardedMap[string, int](4)
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	count := 0
+	sum := 0
+	m.Range(func(key string, value int) bool {
+		count++
+		sum += value
+		return true
+	})
+
+	if count != 3 {
+		t.Errorf("Expected to visit 3 entries, visited %d", count)
+	}
+	if sum != 600 {
+		t.Errorf("Expected sum 600, got %d", sum)
+	}
+
+	// Test early termination
+	count = 0
+	m.Range(func(key string, value int) bool {
+		count++
+		return false // stop after first entry
+	})
+	if count != 1 {
+		t.Errorf("Expected to visit 1 entry, visited %d", count)
+	}
+}
+
+func TestShardedMap_RangeParallel(t *testing.T) {
+	m := NewShardedMap[int, int](8)
+	const n = 1000
+	for i := 0; i < n; i++ {
+		m.Set(i, i)
+	}
+
+	// Every entry is visited exactly once, by no more than the requested number of workers
+	const workers = 3
+	var mu sync.Mutex
+	seen := make(map[int]int)
+	var active, peak atomic.Int64
+	m.RangeParallel(workers, func(k, v int) bool {
+		cur := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+		mu.Lock()
+		seen[k]++
+		mu.Unlock()
+		return true
+	})
+	if len(seen) != n {
+		t.Errorf("Expected %d entries to be visited, got %d", n, len(seen))
+	}
+	for k, count := range seen {
+		if count != 1 {
+			t.Errorf("Expected key %d to be visited once, got %d", k, count)
+		}
+	}
+	if p := peak.Load(); p > workers {
+		t.Errorf("Expected at most %d concurrent calls, got %d", workers, p)
+	}
+
+	// Returning false stops all workers; each may finish the call it is in
+	var calls atomic.Int64
+	m.RangeParallel(workers, func(int, int) bool {
+		calls.Add(1)
+		return false
+	})
+	if c := calls.Load(); c < 1 || c > workers {
+		t.Errorf("Expected between 1 and %d calls after stopping, got %d", workers, c)
+	}
+
+	// Worker counts out of range are clamped
+	for _, w := range []int{0, -1, 100} {
+		var visited atomic.Int64
+		m.RangeParallel(w, func(int, int) bool {
+			visited.Add(1)
+			return true
+		})
+		if visited.Load() != n {
+			t.Errorf("Expected %d entries with %d workers, got %d", n, w, visited.Load())
+		}
+	}
+}
+
+func TestShardedMap_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewShardedMap[int, int](4)
+	const goroutines = 10
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines * 2)
+
+	// Concurrent writes
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := id*iterations + j
+				m.Set(key, key*2)
+			}
+		}(i)
+	}
+
+	// Concurrent reads
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := id*iterations + j
+				m.Get(key)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Verify final count
+	expectedLen := goroutines * iterations
+	if m.Len() != expectedLen {
+		t.Errorf("Expected length %d, got %d", expectedLen, m.Len())
+	}
+}
+
+func TestShardedMap_LenCounter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewShardedMap[int, int](8)
+	const goroutines = 10
+	const iterations = 500
+	const keys = 200
+
+	// Overlapping keys make writers race on adding and removing the same entries,
+	// so only operations that actually add or remove may change the counter
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := (id + j) % keys
+				switch j % 6 {
+				case 0:
+					m.Set(key, j)
+				case 1:
+					m.Delete(key)
+				case 2:
+					m.SetIfAbsent(key, j)
+				case 3:
+					m.GetOrSet(key, j)
+				case 4:
+					Increment[int, int](m, key, 1)
+				case 5:
+					AddMany[int, int](m, map[int]int{key: 1, (key + 1) % keys: 1})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	actual := 0
+	m.Range(func(int, int) bool {
+		actual++
+		return true
+	})
+	if m.Len() != actual {
+		t.Errorf("Expected counter to match snapshot length %d, got %d", actual, m.Len())
+	}
+
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+}
+
+func TestShardedMap_LenNeverNegative(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewShardedMap[int, int](1)
+	const writers = 8
+	const iterations = 20000
+
+	// Writers add and remove the same two keys in a single shard, so a counter updated outside the shard's lock
+	// could apply a removal before the insertion it undoes and briefly go negative
+	var stop atomic.Bool
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		for !stop.Load() {
+			if n := m.Len(); n < 0 {
+				t.Errorf("Expected a non-negative length, got %d", n)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		for !stop.Load() {
+			// Keys and Values size their result with Len, which panics on a negative capacity
+			m.Keys()
+			m.Values()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := j % 2
+				switch (id + j) % 3 {
+				case 0:
+					m.Set(key, j)
+				case 1:
+					m.Delete(key)
+				case 2:
+					m.Clear()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	stop.Store(true)
+	readers.Wait()
+
+	if n, actual := m.Len(), len(m.Keys()); n != actual {
+		t.Errorf("Expected counter to match the %d entries, got %d", actual, n)
+	}
+}
+
+func TestShardedMap_ShardWritesCount(t *testing.T) {
+	m := NewShardedMap[int, int](1)
+	s := m.layout.Load().shards[0]
+
+	// Every write path of a shard keeps the counter in step, not only the ones ShardedMap uses today
+	s.Set(1, 1)
+	s.Merge(map[int]int{2: 2, 3: 3, 4: 4})
+	s.Delete(1)
+	s.DeleteMulti(2, 5)
+	s.Batch(func(tx *Txn[int, int]) {
+		tx.Set(10, 10)
+		tx.Delete(3)
+	})
+	s.SwapMap(map[int]int{20: 20, 21: 21, 22: 22})
+	s.DeleteWhere(func(k, _ int) bool { return k == 20 })
+	if n, actual := m.Len(), s.Len(); n != actual {
+		t.Errorf("Expected counter %d to match the shard, got %d", actual, n)
+	}
+
+	m.Clear()
+	if m.Len() != 0 || s.Len() != 0 {
+		t.Errorf("Expected an empty map after Clear, got counter %d and %d entries", m.Len(), s.Len())
+	}
+}
+
+func TestShardedMap_Distribution(t *testing.T) {
+	m := NewShardedMap[int, int](8)
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	// Every shard should receive some keys
+	for i, s := range m.layout.Load().shards {
+		if s.Len() == 0 {
+			t.Errorf("Expected shard %d to hold some keys", i)
+		}
+	}
+	if m.Len() != 1000 {
+		t.Errorf("Expected length 1000, got %d", m.Len())
+	}
+}
+
+func TestShardedMap_Reshard(t *testing.T) {
+	m := NewShardedMap[int, int](4)
+	const keys = 1000
+	for i := 0; i < keys; i++ {
+		m.Set(i, i*10)
+	}
+
+	// Readers running during resharding must always see every entry exactly once
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				seen := make(map[int]bool, keys)
+				m.Range(func(key, value int) bool {
+					if seen[key] {
+						t.Errorf("Key %d seen twice", key)
+					}
+					seen[key] = true
+					if value != key*10 {
+						t.Errorf("Expected %d=%d, got %d", key, key*10, value)
+					}
+					return true
+				})
+				if len(seen) != keys {
+					t.Errorf("Expected %d entries, saw %d", keys, len(seen))
+					return
+				}
+				if v, ok := m.Get(keys / 2); !ok || v != keys/2*10 {
+					t.Errorf("Expected Get to find %d, got %d, %v", keys/2, v, ok)
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	for _, n := range []int{8, 1, 16, 3, 0} {
+		m.Reshard(n)
+	}
+	close(done)
+	wg.Wait()
+
+	// A count below 1 is treated as 1
+	if n := len(m.layout.Load().shards); n != 1 {
+		t.Errorf("Expected 1 shard, got %d", n)
+	}
+	if m.Len() != keys {
+		t.Errorf("Expected length %d, got %d", keys, m.Len())
+	}
+
+	// Writes keep working after resharding
+	m.Reshard(8)
+	m.Set(keys, 1)
+	m.Delete(0)
+	if m.Len() != keys || !m.Has(keys) || m.Has(0) {
+		t.Errorf("Expected writes to apply to the new layout, got length %d", m.Len())
+	}
+}
+
+func TestShardedMap_ReshardConcurrentWrites(t *testing.T) {
+	m := NewShardedMap[int, int](4)
+	const goroutines = 8
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				m.Set(id*iterations+j, j)
+				Increment[int, int](m, -1, 1)
+			}
+		}(i)
+	}
+	for n := 1; n <= 10; n++ {
+		m.Reshard(n)
+	}
+	wg.Wait()
+
+	// No write is lost while entries move between layouts
+	if m.Len() != goroutines*iterations+1 {
+		t.Errorf("Expected %d entries, got %d", goroutines*iterations+1, m.Len())
+	}
+	if v, _ := m.Get(-1); v != goroutines*iterations {
+		t.Errorf("Expected counter %d, got %d", goroutines*iterations, v)
+	}
+}
+
+func TestShardedMap_InvalidShardCount(t *testing.T) {
+	m := NewShardedMap[string, int](0)
+	m.Set("key1", 100)
+
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+}
@@ -0,0 +1,502 @@
+package mapx
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+// smallMapThreshold is the maximum number of entries SmallMap stores inline before
+// switching to a regular Go map.
+const smallMapThreshold = 16
+
+// SmallMap is a concurrent-safe Map implementation based on CAS + Copy-On-Write,
+// specialized for maps that usually hold only a handful of entries.
+//
+// While the map holds at most 16 entries they are stored inline in a slice and looked up
+// by linear scan, which avoids hashing and makes the copy on every write a single small
+// allocation. Once the map grows past the threshold it switches to a regular Go map, and
+// switches back to the inline representation after shrinking to half the threshold.
+//
+// Advantages:
+//   - Read operations are completely lock-free with excellent performance
+//   - Writes to small maps are much cheaper than CASMap (one small slice copy, no rehashing)
+//   - Implements the Map interface and CASMap's commonly used methods (GetOr, Swap, GetAndDelete,
+//     Merge, Update, CompareAndDelete, Snapshot, All) with the same semantics
+//
+// Disadvantages:
+//   - Lookups in the inline representation are linear, so it only pays off for small maps
+//   - Keys with expensive equality (e.g. long strings) make the linear scan slower
+//   - Under high write concurrency, CAS may fail and retry, degrading performance
+//   - Doesn't offer CASMap's observers, retry options, value cloning or encodings (JSON, gob)
+type SmallMap[K comparable, V any] struct {
+	data atomic.Pointer[smallData[K, V]]
+	eq   func(a, b V) bool // optional equality for conditional operations
+}
+
+// smallEntry is a key-value pair stored inline by SmallMap.
+type smallEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// smallData is an immutable snapshot of a SmallMap.
+// Exactly one representation is in use: entries while m is nil, m otherwise.
+type smallData[K comparable, V any] struct {
+	entries []smallEntry[K, V]
+	m       map[K]V
+}
+
+// NewSmallMap creates a new SmallMap instance.
+func NewSmallMap[K comparable, V any]() *SmallMap[K, V] {
+	m := &SmallMap[K, V]{}
+	m.data.Store(&smallData[K, V]{})
+	return m
+}
+
+// NewSmallMapWithCapacity creates a new SmallMap instance with pre-allocated capacity.
+// A capacity above the inline threshold starts the map in the regular map representation.
+func NewSmallMapWithCapacity[K comparable, V any](capacity int) *SmallMap[K, V] {
+	m := &SmallMap[K, V]{}
+	if capacity > smallMapThreshold {
+		m.data.Store(&smallData[K, V]{m: make(map[K]V, capacity)})
+	} else {
+		m.data.Store(&smallData[K, V]{entries: make([]smallEntry[K, V], 0, capacity)})
+	}
+	return m
+}
+
+// NewSmallMapWithEqual creates a new SmallMap instance that uses eq to compare values
+// in CompareAndSwap and CompareAndDelete instead of the default comparison.
+// This makes the conditional operations usable for values where == is wrong or panics.
+func NewSmallMapWithEqual[K comparable, V any](eq func(a, b V) bool) *SmallMap[K, V] {
+	m := NewSmallMap[K, V]()
+	m.eq = eq
+	return m
+}
+
+// Get retrieves the value associated with the given key.
+// Returns the zero value and false if the key doesn't exist; otherwise returns the value and true.
+// Read operations are completely lock-free with excellent performance.
+func (m *SmallMap[K, V]) Get(key K) (V, bool) {
+	return m.data.Load().get(key)
+}
+
+// GetOr returns the value associated with the given key, or def if the key doesn't exist.
+// Unlike GetOrSet, it never modifies the map.
+func (m *SmallMap[K, V]) GetOr(key K, def V) V {
+	if value, ok := m.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// Set associates the given value with the given key.
+// If the key already exists, the old value will be overwritten.
+// Uses Copy-On-Write + CAS strategy with automatic retry on failure.
+func (m *SmallMap[K, V]) Set(key K, value V) {
+	for {
+		oldData := m.data.Load()
+		newData := oldData.clone()
+		newData.set(key, value)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return
+		}
+		// CAS failed, retry
+	}
+}
+
+// Swap stores the value for the given key and returns the previous value, if any.
+// The loaded result reports whether the key was present, mirroring sync.Map.Swap.
+func (m *SmallMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	for {
+		oldData := m.data.Load()
+		previous, loaded = oldData.get(key)
+		newData := oldData.clone()
+		newData.set(key, value)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return previous, loaded
+		}
+		// CAS failed, retry
+	}
+}
+
+// Delete removes the given key from the map.
+// Has no effect if the key doesn't exist.
+// Uses Copy-On-Write + CAS strategy with automatic retry on failure.
+func (m *SmallMap[K, V]) Delete(key K) {
+	for {
+		oldData := m.data.Load()
+		// Return early if key doesn't exist
+		if _, ok := oldData.get(key); !ok {
+			return
+		}
+		newData := oldData.clone()
+		newData.delete(key)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return
+		}
+		// CAS failed, retry
+	}
+}
+
+// GetAndDelete removes the given key and returns its previous value.
+// Returns the value and true if the key existed; otherwise returns the zero value and false without copying.
+// The read and delete happen inside the same CAS attempt, so two callers never get the same value.
+func (m *SmallMap[K, V]) GetAndDelete(key K) (V, bool) {
+	for {
+		oldData := m.data.Load()
+		v, ok := oldData.get(key)
+		if !ok {
+			return v, false
+		}
+		newData := oldData.clone()
+		newData.delete(key)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return v, true
+		}
+		// CAS failed, retry
+	}
+}
+
+// Len returns the number of key-value pairs in the map.
+func (m *SmallMap[K, V]) Len() int {
+	return m.data.Load().len()
+}
+
+// Has checks whether the given key exists in the map.
+func (m *SmallMap[K, V]) Has(key K) bool {
+	_, ok := m.data.Load().get(key)
+	return ok
+}
+
+// Clear removes all key-value pairs from the map.
+func (m *SmallMap[K, V]) Clear() {
+	m.data.Store(&smallData[K, V]{})
+}
+
+// Merge sets all key-value pairs from other in a single copy-on-write update, switching to the
+// regular map representation if the result exceeds the inline threshold.
+// other is not retained and may be modified afterwards.
+func (m *SmallMap[K, V]) Merge(other map[K]V) {
+	// Return early if there's nothing to merge to avoid unnecessary copy
+	if len(other) == 0 {
+		return
+	}
+	for {
+		oldData := m.data.Load()
+		newData := oldData.clone()
+		for k, v := range other {
+			newData.set(k, v)
+		}
+		if m.data.CompareAndSwap(oldData, newData) {
+			return
+		}
+		// CAS failed, retry
+	}
+}
+
+// Range iterates over all key-value pairs in the map.
+// Calls f for each pair, stopping iteration if f returns false.
+// Note: iteration is over a snapshot; concurrent writes don't affect the current iteration,
+// so it's safe to call write methods within f without deadlock.
+func (m *SmallMap[K, V]) Range(f func(key K, value V) bool) {
+	data := m.data.Load()
+	if data.m != nil {
+		for k, v := range data.m {
+			if !f(k, v) {
+				break
+			}
+		}
+		return
+	}
+	for _, e := range data.entries {
+		if !f(e.key, e.value) {
+			break
+		}
+	}
+}
+
+// All returns an iterator over all key-value pairs in the map, for use with range-over-func.
+// Like Range, it iterates over the snapshot taken when iteration starts.
+func (m *SmallMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(yield)
+	}
+}
+
+// Keys returns a slice containing all keys in the map.
+func (m *SmallMap[K, V]) Keys() []K {
+	data := m.data.Load()
+	keys := make([]K, 0, data.len())
+	if data.m != nil {
+		for k := range data.m {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+	for _, e := range data.entries {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// Values returns a slice containing all values in the map.
+func (m *SmallMap[K, V]) Values() []V {
+	data := m.data.Load()
+	values := make([]V, 0, data.len())
+	if data.m != nil {
+		for _, v := range data.m {
+			values = append(values, v)
+		}
+		return values
+	}
+	for _, e := range data.entries {
+		values = append(values, e.value)
+	}
+	return values
+}
+
+// Snapshot returns a newly allocated plain map with the current contents.
+// The caller owns the returned map and may modify it freely.
+func (m *SmallMap[K, V]) Snapshot() map[K]V {
+	data := m.data.Load()
+	snapshot := make(map[K]V, data.len())
+	if data.m != nil {
+		for k, v := range data.m {
+			snapshot[k] = v
+		}
+		return snapshot
+	}
+	for _, e := range data.entries {
+		snapshot[e.key] = e.value
+	}
+	return snapshot
+}
+
+// GetOrSet retrieves the value for the given key, or sets it to the given value if it doesn't exist.
+// Returns the value and true if the key already existed; otherwise returns the new value and false.
+func (m *SmallMap[K, V]) GetOrSet(key K, value V) (V, bool) {
+	for {
+		oldData := m.data.Load()
+		if v, ok := oldData.get(key); ok {
+			return v, true
+		}
+		newData := oldData.clone()
+		newData.set(key, value)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return value, false
+		}
+		// CAS failed, retry
+	}
+}
+
+// SetIfAbsent sets the value for the given key only if it doesn't already exist.
+// Returns true if the value was set, false if the key already existed.
+func (m *SmallMap[K, V]) SetIfAbsent(key K, value V) bool {
+	_, existed := m.GetOrSet(key, value)
+	return !existed
+}
+
+// CompareAndSwap atomically compares and swaps: sets newValue only if current value equals oldValue.
+// Returns true if the swap succeeded, false if it failed (key doesn't exist or value doesn't match).
+// Values are compared with the map's equality function if one was supplied; otherwise values of
+// non-comparable types such as slices are compared with reflect.DeepEqual.
+func (m *SmallMap[K, V]) CompareAndSwap(key K, oldValue, newValue V) bool {
+	for {
+		oldData := m.data.Load()
+		v, ok := oldData.get(key)
+		if !ok || !m.equal(v, oldValue) {
+			return false
+		}
+		newData := oldData.clone()
+		newData.set(key, newValue)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return true
+		}
+		// CAS failed, retry
+	}
+}
+
+// CompareAndDelete atomically deletes the given key only if its current value equals oldValue.
+// Returns true if the key was deleted, false if it doesn't exist or its value doesn't match.
+// Values are compared the same way as in CompareAndSwap.
+func (m *SmallMap[K, V]) CompareAndDelete(key K, oldValue V) bool {
+	for {
+		oldData := m.data.Load()
+		v, ok := oldData.get(key)
+		if !ok || !m.equal(v, oldValue) {
+			return false
+		}
+		newData := oldData.clone()
+		newData.delete(key)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return true
+		}
+		// CAS failed, retry
+	}
+}
+
+// Update atomically replaces the value for the given key with the result of f and returns the new value.
+// f receives the current value (or the zero value) and whether the key exists.
+// The whole copy-modify-store runs inside the CAS retry loop, so f may be called more than once
+// and must be free of side effects.
+func (m *SmallMap[K, V]) Update(key K, f func(old V, exists bool) V) V {
+	for {
+		oldData := m.data.Load()
+		newValue := f(oldData.get(key))
+		newData := oldData.clone()
+		newData.set(key, newValue)
+		if m.data.CompareAndSwap(oldData, newData) {
+			return newValue
+		}
+		// CAS failed, retry
+	}
+}
+
+// compute atomically replaces the values of keys with the ones returned by f.
+// f is called again with the latest values whenever the CAS fails, so it may run more than once per key.
+func (m *SmallMap[K, V]) compute(keys []K, f func(key K, value V, exists bool) (V, bool)) bool {
+	for {
+		oldData := m.data.Load()
+		var newData *smallData[K, V]
+		for _, key := range keys {
+			v, ok := oldData.get(key)
+			newValue, store := f(key, v, ok)
+			if !store {
+				continue
+			}
+			if newData == nil {
+				newData = oldData.clone()
+			}
+			newData.set(key, newValue)
+		}
+		if newData == nil {
+			return false
+		}
+		if m.data.CompareAndSwap(oldData, newData) {
+			return true
+		}
+		// CAS failed, retry
+	}
+}
+
+// update atomically applies the op returned by f to key.
+// f is called again with the latest value whenever the CAS fails, so it may run more than once.
+func (m *SmallMap[K, V]) update(key K, f func(value V, exists bool) (V, updateOp)) bool {
+	for {
+		oldData := m.data.Load()
+		v, ok := oldData.get(key)
+		newValue, op := f(v, ok)
+		if op == updateKeep || (op == updateDelete && !ok) {
+			return false
+		}
+		newData := oldData.clone()
+		if op == updateDelete {
+			newData.delete(key)
+		} else {
+			newData.set(key, newValue)
+		}
+		if m.data.CompareAndSwap(oldData, newData) {
+			return true
+		}
+		// CAS failed, retry
+	}
+}
+
+// equal compares two values with the map's equality function, falling back to compare.
+func (m *SmallMap[K, V]) equal(a, b V) bool {
+	if m.eq != nil {
+		return m.eq(a, b)
+	}
+	return compare(a, b)
+}
+
+// get looks up key in the snapshot.
+func (d *smallData[K, V]) get(key K) (V, bool) {
+	if d.m != nil {
+		v, ok := d.m[key]
+		return v, ok
+	}
+	for i := range d.entries {
+		if d.entries[i].key == key {
+			return d.entries[i].value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// len returns the number of entries in the snapshot.
+func (d *smallData[K, V]) len() int {
+	if d.m != nil {
+		return len(d.m)
+	}
+	return len(d.entries)
+}
+
+// clone creates a private copy of the snapshot that can be modified with set and delete
+// before being published. This is the core implementation of the Copy-On-Write strategy.
+func (d *smallData[K, V]) clone() *smallData[K, V] {
+	if d.m != nil {
+		newMap := make(map[K]V, len(d.m))
+		for k, v := range d.m {
+			newMap[k] = v
+		}
+		return &smallData[K, V]{m: newMap}
+	}
+	// Reserve room for one more entry so the common single insert doesn't reallocate
+	entries := make([]smallEntry[K, V], len(d.entries), len(d.entries)+1)
+	copy(entries, d.entries)
+	return &smallData[K, V]{entries: entries}
+}
+
+// set stores the value for key in a private copy, switching to the map representation
+// when the inline entries exceed the threshold.
+func (d *smallData[K, V]) set(key K, value V) {
+	if d.m != nil {
+		d.m[key] = value
+		return
+	}
+	for i := range d.entries {
+		if d.entries[i].key == key {
+			d.entries[i].value = value
+			return
+		}
+	}
+	if len(d.entries) < smallMapThreshold {
+		d.entries = append(d.entries, smallEntry[K, V]{key: key, value: value})
+		return
+	}
+	d.m = make(map[K]V, len(d.entries)+1)
+	for _, e := range d.entries {
+		d.m[e.key] = e.value
+	}
+	d.m[key] = value
+	d.entries = nil
+}
+
+// delete removes key from a private copy, switching back to the inline representation
+// when the map shrinks to half the threshold.
+func (d *smallData[K, V]) delete(key K) {
+	if d.m != nil {
+		delete(d.m, key)
+		if len(d.m) > smallMapThreshold/2 {
+			return
+		}
+		d.entries = make([]smallEntry[K, V], 0, len(d.m)+1)
+		for k, v := range d.m {
+			d.entries = append(d.entries, smallEntry[K, V]{key: k, value: v})
+		}
+		d.m = nil
+		return
+	}
+	for i := range d.entries {
+		if d.entries[i].key == key {
+			last := len(d.entries) - 1
+			d.entries[i] = d.entries[last]
+			d.entries[last] = smallEntry[K, V]{}
+			d.entries = d.entries[:last]
+			return
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package mapx
+
+import (
+	"maps"
+	"sync"
+	"testing"
+)
+
+func TestSmallMap_BasicOperations(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	// Test Set and Get
+	m.Set("key1", 100)
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+
+	// Test Get non-existent key
+	if val, ok := m.Get("key2"); ok {
+		t.Errorf("Expected (0, false), got (%d, true)", val)
+	}
+
+	// Test Has
+	if !m.Has("key1") {
+		t.Error("Expected key1 to exist")
+	}
+	if m.Has("key2") {
+		t.Error("Expected key2 to not exist")
+	}
+
+	// Test Len
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1, got %d", m.Len())
+	}
+
+	// Test Delete
+	m.Delete("key1")
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0, got %d", m.Len())
+	}
+
+	// Test Delete non-existent key (should not panic)
+	m.Delete("nonexistent")
+}
+
+func TestSmallMap_GetOrSet(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	// First call should set the value
+	val, existed := m.GetOrSet("key1", 100)
+	if existed || val != 100 {
+		t.Errorf("Expected (100, false), got (%d, %v)", val, existed)
+	}
+
+	// Second call should return existing value
+	val, existed = m.GetOrSet("key1", 200)
+	if !existed || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, existed)
+	}
+}
+
+func TestSmallMap_SetIfAbsent(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	// Should set successfully
+	if !m.SetIfAbsent("key1", 100) {
+		t.Error("Expected SetIfAbsent to succeed")
+	}
+
+	// Should fail on second attempt
+	if m.SetIfAbsent("key1", 200) {
+		t.Error("Expected SetIfAbsent to fail")
+	}
+
+	// Value should remain unchanged
+	if val, _ := m.Get("key1"); val != 100 {
+		t.Errorf("Expected value 100, got %d", val)
+	}
+}
+
+func TestSmallMap_CompareAndSwap(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	// CAS on non-existent key should fail
+	if m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to fail on non-existent key")
+	}
+
+	m.Set("key1", 100)
+
+	// CAS with wrong old value should fail
+	if m.CompareAndSwap("key1", 999, 200) {
+		t.Error("Expected CAS to fail with wrong old value")
+	}
+
+	// CAS with correct old value should succeed
+	if !m.CompareAndSwap("key1", 100, 200) {
+		t.Error("Expected CAS to succeed")
+	}
+
+	// Verify new value
+	if val, _ := m.Get("key1"); val != 200 {
+		t.Errorf("Expected value 200, got %d", val)
+	}
+}
+
+func TestSmallMap_CompareAndDelete(t *testing.T) {
+	m := NewSmallMap[string, int]()
+	m.Set("key1", 100)
+
+	if m.CompareAndDelete("key1", 999) || !m.Has("key1") {
+		t.Error("Expected CompareAndDelete to fail with wrong old value")
+	}
+	if !m.CompareAndDelete("key1", 100) || m.Has("key1") {
+		t.Error("Expected CompareAndDelete to delete with matching old value")
+	}
+	if m.CompareAndDelete("missing", 0) {
+		t.Error("Expected CompareAndDelete to fail on non-existent key")
+	}
+}
+
+func TestSmallMap_WithEqual(t *testing.T) {
+	type event struct {
+		ID   int
+		Seen int
+	}
+	// Compare by ID only, ignoring when the event was seen
+	m := NewSmallMapWithEqual[string](func(a, b event) bool { return a.ID == b.ID })
+	m.Set("key1", event{ID: 1, Seen: 10})
+
+	if !m.CompareAndSwap("key1", event{ID: 1}, event{ID: 2}) {
+		t.Error("Expected CAS to succeed using the custom equality")
+	}
+	if !m.CompareAndDelete("key1", event{ID: 2}) {
+		t.Error("Expected CompareAndDelete to succeed using the custom equality")
+	}
+}
+
+func TestSmallMap_SwapAndGetAndDelete(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	if prev, loaded := m.Swap("key1", 100); loaded || prev != 0 {
+		t.Errorf("Expected (0, false) when creating the key, got (%d, %v)", prev, loaded)
+	}
+	if prev, loaded := m.Swap("key1", 200); !loaded || prev != 100 {
+		t.Errorf("Expected (100, true) when updating the key, got (%d, %v)", prev, loaded)
+	}
+	if v, ok := m.GetAndDelete("key1"); !ok || v != 200 || m.Has("key1") {
+		t.Errorf("Expected GetAndDelete to remove and return 200, got (%d, %v)", v, ok)
+	}
+	if _, ok := m.GetAndDelete("key1"); ok {
+		t.Error("Expected GetAndDelete to fail on a missing key")
+	}
+	if v := m.GetOr("key1", -1); v != -1 {
+		t.Errorf("Expected default -1, got %d", v)
+	}
+}
+
+func TestSmallMap_Update(t *testing.T) {
+	m := NewSmallMap[string, int]()
+
+	if v := m.Update("hits", func(old int, exists bool) int {
+		if exists {
+			t.Error("Expected the key not to exist yet")
+		}
+		return old + 1
+	}); v != 1 {
+		t.Errorf("Expected 1, got %d", v)
+	}
+	if v := m.Update("hits", func(old int, _ bool) int { return old + 1 }); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+}
+
+func TestSmallMap_MergeAndSnapshot(t *testing.T) {
+	m := NewSmallMap[int, int]()
+	m.Set(0, 0)
+
+	// A merge that crosses the inline threshold switches representation in the same update
+	other := make(map[int]int)
+	for i := 1; i <= smallMapThreshold; i++ {
+		other[i] = i
+	}
+	m.Merge(other)
+	if m.data.Load().m == nil {
+		t.Error("Expected map representation after merging past the threshold")
+	}
+
+	expected := maps.Clone(other)
+	expected[0] = 0
+	snapshot := m.Snapshot()
+	if !maps.Equal(snapshot, expected) {
+		t.Errorf("Expected %v, got %v", expected, snapshot)
+	}
+	if all := maps.Collect(m.All()); !maps.Equal(all, expected) {
+		t.Errorf("Expected All to yield %v, got %v", expected, all)
+	}
+
+	// The snapshot is owned by the caller
+	snapshot[0] = 100
+	if v, _ := m.Get(0); v != 0 {
+		t.Errorf("Expected modifying the snapshot not to affect the map, got %d", v)
+	}
+}
+
+func TestSmallMap_Clear(t *testing.T) {
+	m := NewSmallMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	m.Clear()
+
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+	if m.Has("key1") {
+		t.Error("Expected all keys to be cleared")
+	}
+}
+
+func TestSmallMap_Keys(t *testing.T) {
+	m := NewSmallMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	keys := m.Keys()
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 keys, got %d", len(keys))
+	}
+
+	keyMap := make(map[string]bool)
+	for _, k := range keys {
+		keyMap[k] = true
+	}
+	if !keyMap["key1"] || !keyMap["key2"] || !keyMap["key3"] {
+		t.Error("Keys not returned correctly")
+	}
+}
+
+func TestSmallMap_Values(t *testing.T) {
+	m := NewSmallMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	values := m.Values()
+	if len(values) != 3 {
+		t.Errorf("Expected 3 values, got %d", len(values))
+	}
+
+	valueMap := make(map[int]bool)
+	for _, v := range values {
+		valueMap[v] = true
+	}
+	if !valueMap[100] || !valueMap[200] || !valueMap[300] {
+		t.Error("Values not returned correctly")
+	}
+}
+
+func TestSmallMap_Range(t *testing.T) {
+	m := NewSmallMap[string, int]()
+	m.Set("key1", 100)
+	m.Set("key2", 200)
+	m.Set("key3", 300)
+
+	count := 0
+	sum := 0
+	m.Range(func(key string, value int) bool {
+		count++
+		sum += value
+		return true
+	})
+
+	if count != 3 {
+		t.Errorf("Expected to visit 3 entries, visited %d", count)
+	}
+	if sum != 600 {
+		t.Errorf("Expected sum 600, got %d", sum)
+	}
+
+	// Test early termination
+	count = 0
+	m.Range(func(key string, value int) bool {
+		count++
+		return false // stop after first entry
+	})
+	if count != 1 {
+		t.Errorf("Expected to visit 1 entry, visited %d", count)
+	}
+}
+
+func TestSmallMap_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long-running concurrent test in short mode")
+	}
+
+	m := NewSmallMap[int, int]()
+	const goroutines = 10
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines * 2)
+
+	// Concurrent writes
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := id*iterations + j
+				m.Set(key, key*2)
+			}
+		}(i)
+	}
+
+	// Concurrent reads
+	for i := 0; i < goroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				key := id*iterations + j
+				m.Get(key)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Verify final count
+	expectedLen := goroutines * iterations
+	if m.Len() != expectedLen {
+		t.Errorf("Expected length %d, got %d", expectedLen, m.Len())
+	}
+}
+
+func TestSmallMap_WithCapacity(t *testing.T) {
+	m := NewSmallMapWithCapacity[string, int](100)
+	m.Set("key1", 100)
+
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+}
+
+func TestSmallMap_Representation(t *testing.T) {
+	m := NewSmallMap[int, int]()
+
+	// Fill past the inline threshold so the map switches representation
+	const n = smallMapThreshold * 2
+	for i := 0; i < n; i++ {
+		m.Set(i, i*2)
+	}
+	if m.data.Load().m == nil {
+		t.Error("Expected map representation above the threshold")
+	}
+	for i := 0; i < n; i++ {
+		if val, ok := m.Get(i); !ok || val != i*2 {
+			t.Errorf("Expected (%d, true), got (%d, %v)", i*2, val, ok)
+		}
+	}
+
+	// Shrink to half the threshold so the map switches back
+	for i := 0; i < n-smallMapThreshold/2; i++ {
+		m.Delete(i)
+	}
+	if m.data.Load().m != nil {
+		t.Error("Expected inline representation after shrinking")
+	}
+	if m.Len() != smallMapThreshold/2 {
+		t.Errorf("Expected length %d, got %d", smallMapThreshold/2, m.Len())
+	}
+	for i := n - smallMapThreshold/2; i < n; i++ {
+		if val, ok := m.Get(i); !ok || val != i*2 {
+			t.Errorf("Expected (%d, true), got (%d, %v)", i*2, val, ok)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package mapx
+
+// Strategy selects the Map implementation created by New.
+type Strategy int
+
+const (
+	// StrategyCAS selects CASMap, which suits read-heavy maps with rare, uncontended writes.
+	StrategyCAS Strategy = iota
+	// StrategyRWMutex selects RWMutexMap, whose writes never retry under contention.
+	StrategyRWMutex
+	// StrategySharded selects a ShardedMap with DefaultShards shards, which suits large maps with frequent writes.
+	StrategySharded
+)
+
+// DefaultShards is the number of shards of the ShardedMap created by New with StrategySharded.
+const DefaultShards = 16
+
+// String returns the name of the strategy.
+func (s Strategy) String() string {
+	switch s {
+	case StrategyCAS:
+		return "cas"
+	case StrategyRWMutex:
+		return "rwmutex"
+	case StrategySharded:
+		return "sharded"
+	default:
+		return "unknown"
+	}
+}
+
+// New creates an empty map backed by the implementation selected by strategy, so that the
+// implementation can be swapped with a single argument while callers keep using the Map interface.
+// An unknown strategy is treated as StrategyCAS, the zero value.
+// Use the type-specific constructors to access methods outside the Map interface.
+func New[K comparable, V any](strategy Strategy) Map[K, V] {
+	switch strategy {
+	case StrategyRWMutex:
+		return NewRWMutexMap[K, V]()
+	case StrategySharded:
+		return NewShardedMap[K, V](DefaultShards)
+	default:
+		return NewCASMap[K, V]()
+	}
+}
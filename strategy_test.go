@@ -0,0 +1,96 @@
+package mapx
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// strategies lists every strategy accepted by New.
+var strategies = []Strategy{StrategyCAS, StrategyRWMutex, StrategySharded}
+
+func TestNew_Implementation(t *testing.T) {
+	if _, ok := New[string, int](StrategyCAS).(*CASMap[string, int]); !ok {
+		t.Error("Expected StrategyCAS to create a CASMap")
+	}
+	if _, ok := New[string, int](StrategyRWMutex).(*RWMutexMap[string, int]); !ok {
+		t.Error("Expected StrategyRWMutex to create a RWMutexMap")
+	}
+	if _, ok := New[string, int](StrategySharded).(*ShardedMap[string, int]); !ok {
+		t.Error("Expected StrategySharded to create a ShardedMap")
+	}
+	if _, ok := New[string, int](Strategy(99)).(*CASMap[string, int]); !ok {
+		t.Error("Expected an unknown strategy to create a CASMap")
+	}
+}
+
+func TestNew_Operations(t *testing.T) {
+	for _, strategy := range strategies {
+		t.Run(strategy.String(), func(t *testing.T) {
+			m := New[string, int](strategy)
+
+			m.Set("key1", 100)
+			m.Set("key2", 200)
+			if v, ok := m.Get("key1"); !ok || v != 100 {
+				t.Errorf("Expected key1=100, got %d, %v", v, ok)
+			}
+			if v, loaded := m.GetOrSet("key1", 1); !loaded || v != 100 {
+				t.Errorf("Expected GetOrSet to return existing 100, got %d, %v", v, loaded)
+			}
+			if !m.SetIfAbsent("key3", 300) || m.SetIfAbsent("key3", 1) {
+				t.Error("Expected SetIfAbsent to only set a missing key")
+			}
+			if !m.CompareAndSwap("key2", 200, 201) || m.CompareAndSwap("key2", 200, 202) {
+				t.Error("Expected CompareAndSwap to only swap a matching value")
+			}
+			if Increment(m, "key4", 5) != 5 {
+				t.Error("Expected Increment to treat a missing key as zero")
+			}
+
+			keys := m.Keys()
+			slices.Sort(keys)
+			if !slices.Equal(keys, []string{"key1", "key2", "key3", "key4"}) || m.Len() != 4 {
+				t.Errorf("Expected four keys, got %v", keys)
+			}
+
+			m.Delete("key1")
+			if m.Has("key1") || m.Len() != 3 {
+				t.Errorf("Expected key1 to be deleted, got %v", m.Keys())
+			}
+			m.Clear()
+			if m.Len() != 0 {
+				t.Errorf("Expected empty map after Clear, got %v", m.Keys())
+			}
+		})
+	}
+}
+
+func TestNew_Concurrent(t *testing.T) {
+	for _, strategy := range strategies {
+		t.Run(strategy.String(), func(t *testing.T) {
+			m := New[int, int](strategy)
+			const goroutines = 10
+			const iterations = 100
+
+			var wg sync.WaitGroup
+			wg.Add(goroutines)
+			for i := 0; i < goroutines; i++ {
+				go func(id int) {
+					defer wg.Done()
+					for j := 0; j < iterations; j++ {
+						m.Set(id*iterations+j, j)
+						Increment(m, -1, 1)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if m.Len() != goroutines*iterations+1 {
+				t.Errorf("Expected %d entries, got %d", goroutines*iterations+1, m.Len())
+			}
+			if v, _ := m.Get(-1); v != goroutines*iterations {
+				t.Errorf("Expected counter %d, got %d", goroutines*iterations, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package mapx
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLMap is a concurrent-safe map whose entries expire after a per-entry time-to-live.
+//
+// It is built on RWMutexMap, so reads are lock-free and writes use Mutex + Copy-On-Write.
+// Expired entries are treated as absent by all read methods as soon as they expire, and are
+// physically removed by a background janitor goroutine that sweeps the map periodically.
+// Call Close to stop the janitor when the map is no longer needed.
+type TTLMap[K comparable, V any] struct {
+	data *RWMutexMap[K, ttlEntry[V]]
+	now  func() time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ttlEntry is a value stored in a TTLMap together with its expiration time.
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time // zero means the entry never expires
+}
+
+// expired reports whether the entry has expired at the given time.
+func (e ttlEntry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewTTLMap creates a new TTLMap instance whose janitor sweeps expired entries every cleanupInterval.
+// If cleanupInterval is not positive no janitor is started, and expired entries are only removed
+// by explicit calls to DeleteExpired.
+func NewTTLMap[K comparable, V any](cleanupInterval time.Duration) *TTLMap[K, V] {
+	m := &TTLMap[K, V]{
+		data: NewRWMutexMap[K, ttlEntry[V]](),
+		now:  time.Now,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go m.janitor(cleanupInterval)
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+// janitor periodically removes expired entries until Close is called.
+func (m *TTLMap[K, V]) janitor(interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Close stops the janitor goroutine and waits for it to exit.
+// The map remains usable afterwards, but expired entries are no longer swept automatically.
+// It is safe to call Close more than once.
+func (m *TTLMap[K, V]) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+// Get retrieves the value associated with the given key.
+// Returns the zero value and false if the key doesn't exist or has expired.
+func (m *TTLMap[K, V]) Get(key K) (V, bool) {
+	e, ok := m.data.Get(key)
+	if !ok || e.expired(m.now()) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Has checks whether the given key exists in the map and has not expired.
+func (m *TTLMap[K, V]) Has(key K) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Set associates the given value with the given key without an expiration time.
+// If the key already exists, the old value and its expiration time will be overwritten.
+func (m *TTLMap[K, V]) Set(key K, value V) {
+	m.data.Set(key, ttlEntry[V]{value: value})
+}
+
+// SetWithTTL associates the given value with the given key, expiring it after ttl.
+// If the key already exists, the old value and its expiration time will be overwritten.
+// A ttl that is not positive stores the value without an expiration time.
+func (m *TTLMap[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	e := ttlEntry[V]{value: value}
+	if ttl > 0 {
+		e.expiresAt = m.now().Add(ttl)
+	}
+	m.data.Set(key, e)
+}
+
+// Delete removes the given key from the map.
+// Has no effect if the key doesn't exist.
+func (m *TTLMap[K, V]) Delete(key K) {
+	m.data.Delete(key)
+}
+
+// Len returns the number of unexpired key-value pairs in the map.
+// It scans the whole map to skip expired entries, so it is O(n).
+func (m *TTLMap[K, V]) Len() int {
+	now := m.now()
+	n := 0
+	for _, e := range m.data.load() {
+		if !e.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Range iterates over all unexpired key-value pairs in the map.
+// Calls f for each pair, stopping iteration if f returns false.
+// Note: iteration is over a snapshot, so it's safe to call write methods within f without deadlock.
+func (m *TTLMap[K, V]) Range(f func(key K, value V) bool) {
+	now := m.now()
+	for k, e := range m.data.load() {
+		if e.expired(now) {
+			continue
+		}
+		if !f(k, e.value) {
+			break
+		}
+	}
+}
+
+// Clear removes all key-value pairs from the map.
+func (m *TTLMap[K, V]) Clear() {
+	m.data.Clear()
+}
+
+// DeleteExpired removes all expired entries in a single copy-on-write update and
+// returns the number of entries removed. It is called periodically by the janitor.
+func (m *TTLMap[K, V]) DeleteExpired() int {
+	d := m.data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := m.now()
+	oldMap := d.load()
+	removed := 0
+	for _, e := range oldMap {
+		if e.expired(now) {
+			removed++
+		}
+	}
+	// Return early if nothing expired to avoid unnecessary copy
+	if removed == 0 {
+		return 0
+	}
+	newMap := make(map[K]ttlEntry[V], len(oldMap)-removed)
+	for k, e := range oldMap {
+		if !e.expired(now) {
+			newMap[k] = e
+		}
+	}
+	d.data.Store(&newMap)
+	return removed
+}
@@ -0,0 +1,157 @@
+package mapx
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic expiry tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newFakeTTLMap creates a TTLMap without janitor driven by a fake clock.
+func newFakeTTLMap[K comparable, V any]() (*TTLMap[K, V], *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m := NewTTLMap[K, V](0)
+	m.now = clock.Now
+	return m, clock
+}
+
+func TestTTLMap_BasicOperations(t *testing.T) {
+	m, _ := newFakeTTLMap[string, int]()
+	defer m.Close()
+
+	m.Set("key1", 100)
+	if val, ok := m.Get("key1"); !ok || val != 100 {
+		t.Errorf("Expected (100, true), got (%d, %v)", val, ok)
+	}
+	if !m.Has("key1") {
+		t.Error("Expected key1 to exist")
+	}
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1, got %d", m.Len())
+	}
+
+	m.Delete("key1")
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+
+	m.Set("key2", 200)
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Expected length 0 after clear, got %d", m.Len())
+	}
+}
+
+func TestTTLMap_Expiry(t *testing.T) {
+	m, clock := newFakeTTLMap[string, int]()
+	defer m.Close()
+
+	m.SetWithTTL("short", 1, time.Second)
+	m.SetWithTTL("long", 2, time.Minute)
+	m.Set("forever", 3)
+
+	clock.Advance(2 * time.Second)
+
+	// Expired but not yet swept entries are treated as absent
+	if _, ok := m.Get("short"); ok {
+		t.Error("Expected short to be expired")
+	}
+	if val, ok := m.Get("long"); !ok || val != 2 {
+		t.Errorf("Expected (2, true), got (%d, %v)", val, ok)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Expected length 2, got %d", m.Len())
+	}
+	count := 0
+	m.Range(func(key string, value int) bool {
+		if key == "short" {
+			t.Error("Expected Range to skip expired entries")
+		}
+		count++
+		return true
+	})
+	if count != 2 {
+		t.Errorf("Expected to visit 2 entries, visited %d", count)
+	}
+
+	clock.Advance(time.Hour)
+	if removed := m.DeleteExpired(); removed != 2 {
+		t.Errorf("Expected 2 expired entries removed, got %d", removed)
+	}
+	if m.data.Len() != 1 || !m.Has("forever") {
+		t.Error("Expected only the entry without TTL to remain")
+	}
+
+	// Nothing left to sweep
+	if removed := m.DeleteExpired(); removed != 0 {
+		t.Errorf("Expected 0 entries removed, got %d", removed)
+	}
+}
+
+func TestTTLMap_SetResetsTTL(t *testing.T) {
+	m, clock := newFakeTTLMap[string, int]()
+	defer m.Close()
+
+	m.SetWithTTL("key1", 100, time.Second)
+	m.Set("key1", 200)
+
+	clock.Advance(time.Hour)
+	if val, ok := m.Get("key1"); !ok || val != 200 {
+		t.Errorf("Expected (200, true), got (%d, %v)", val, ok)
+	}
+}
+
+func TestTTLMap_Janitor(t *testing.T) {
+	m := NewTTLMap[string, int](5 * time.Millisecond)
+	defer m.Close()
+
+	m.SetWithTTL("key1", 100, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for m.data.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected janitor to sweep the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTTLMap_Close(t *testing.T) {
+	m := NewTTLMap[string, int](time.Millisecond)
+	m.Close()
+
+	// The janitor has exited, so expired entries are no longer swept
+	select {
+	case <-m.done:
+	default:
+		t.Fatal("Expected janitor to be stopped after Close")
+	}
+	m.SetWithTTL("key1", 100, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if m.data.Len() != 1 {
+		t.Error("Expected expired entry to remain unswept after Close")
+	}
+	if m.Has("key1") {
+		t.Error("Expected key1 to be expired")
+	}
+
+	// Closing twice is safe
+	m.Close()
+}
@@ -0,0 +1,41 @@
+package mapx
+
+// Txn buffers the writes of a Batch so that they can be applied in a single copy-on-write update.
+// Writes are applied in the order they were made, so a later write to a key overrides an earlier one.
+// A Txn is only valid inside the function passed to Batch and must not be used concurrently.
+type Txn[K comparable, V any] struct {
+	ops []txnOp[K, V]
+}
+
+// txnOp is a single buffered write of a Txn.
+type txnOp[K comparable, V any] struct {
+	key    K
+	value  V
+	delete bool
+}
+
+// Set buffers setting key to value.
+func (tx *Txn[K, V]) Set(key K, value V) {
+	tx.ops = append(tx.ops, txnOp[K, V]{key: key, value: value})
+}
+
+// Delete buffers removing key. Has no effect if the key doesn't exist when the batch is applied.
+func (tx *Txn[K, V]) Delete(key K) {
+	tx.ops = append(tx.ops, txnOp[K, V]{key: key, delete: true})
+}
+
+// apply applies the buffered writes to data, which must be a private copy, recording them in c.
+func (tx *Txn[K, V]) apply(data map[K]V, c *changeSet[K, V]) {
+	for _, op := range tx.ops {
+		old, existed := data[op.key]
+		if op.delete {
+			if existed {
+				delete(data, op.key)
+				c.delete(op.key, old)
+			}
+			continue
+		}
+		data[op.key] = op.value
+		c.set(op.key, old, existed, op.value)
+	}
+}
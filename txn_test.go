@@ -0,0 +1,138 @@
+package mapx
+
+import (
+	"sync"
+	"testing"
+)
+
+// batcher is implemented by the maps that support Batch.
+type batcher[K comparable, V any] interface {
+	Map[K, V]
+	Batch(f func(tx *Txn[K, V]))
+	OnChange(f func(event ChangeEvent[K, V])) (unregister func())
+}
+
+func TestBatch(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m batcher[string, int]) {
+		m.Set("key1", 100)
+		m.Set("key3", 300)
+
+		m.Batch(func(tx *Txn[string, int]) {
+			tx.Set("key1", 1)
+			tx.Set("key2", 2)
+			tx.Delete("key3")
+			tx.Delete("missing")
+
+			// Reads inside the batch see the pre-batch state
+			if v, _ := m.Get("key1"); v != 100 {
+				t.Errorf("Expected pre-batch value 100 inside the batch, got %d", v)
+			}
+			if !m.Has("key3") {
+				t.Error("Expected key3 to exist inside the batch")
+			}
+		})
+
+		if v, _ := m.Get("key1"); v != 1 {
+			t.Errorf("Expected key1=1, got %d", v)
+		}
+		if v, _ := m.Get("key2"); v != 2 {
+			t.Errorf("Expected key2=2, got %d", v)
+		}
+		if m.Has("key3") || m.Len() != 2 {
+			t.Errorf("Expected key3 to be deleted, got %v", m.Keys())
+		}
+
+		// Later writes to the same key win
+		m.Batch(func(tx *Txn[string, int]) {
+			tx.Set("key4", 4)
+			tx.Delete("key4")
+			tx.Set("key1", 10)
+			tx.Set("key1", 11)
+		})
+		if v, _ := m.Get("key1"); v != 11 || m.Has("key4") {
+			t.Errorf("Expected key1=11 and no key4, got %v", m.Keys())
+		}
+	})
+}
+
+func TestBatch_Events(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m batcher[string, int]) {
+		m.Set("key1", 100)
+		var events []ChangeEvent[string, int]
+		m.OnChange(func(event ChangeEvent[string, int]) {
+			events = append(events, event)
+		})
+
+		// An empty batch doesn't write
+		m.Batch(func(tx *Txn[string, int]) {})
+		m.Batch(func(tx *Txn[string, int]) {
+			tx.Set("key2", 200)
+			tx.Delete("key1")
+			tx.Delete("missing")
+		})
+
+		expected := []ChangeEvent[string, int]{
+			{Op: ChangeSet, Key: "key2", NewValue: 200},
+			{Op: ChangeDelete, Key: "key1", OldValue: 100, Existed: true},
+		}
+		if len(events) != len(expected) {
+			t.Fatalf("Expected %d events, got %v", len(expected), events)
+		}
+		for i := range expected {
+			if events[i] != expected[i] {
+				t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], events[i])
+			}
+		}
+	})
+}
+
+func TestBatch_Atomic(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m batcher[string, int]) {
+		m.Set("c", 0)
+
+		// Each batch moves the map between {c} and {a, b}; no reader may observe a mix
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				snapshot := make(map[string]int)
+				m.Range(func(key string, value int) bool {
+					snapshot[key] = value
+					return true
+				})
+				_, a := snapshot["a"]
+				_, b := snapshot["b"]
+				_, c := snapshot["c"]
+				if a != b || a == c {
+					t.Errorf("Observed a partially applied batch: %v", snapshot)
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				m.Batch(func(tx *Txn[string, int]) {
+					tx.Set("a", i)
+					tx.Set("b", i)
+					tx.Delete("c")
+				})
+			} else {
+				m.Batch(func(tx *Txn[string, int]) {
+					tx.Delete("a")
+					tx.Delete("b")
+					tx.Set("c", i)
+				})
+			}
+		}
+		close(done)
+		wg.Wait()
+	})
+}
@@ -0,0 +1,77 @@
+package mapx
+
+import "weak"
+
+// WeakCASMap is a concurrent-safe map that holds its values through weak pointers, so the garbage
+// collector may reclaim a value once nothing else references it.
+//
+// It is built on CASMap, so reads are lock-free and writes use CAS + Copy-On-Write. Entries whose
+// value has been reclaimed are treated as absent by all read methods as soon as the collector clears
+// them, but keep occupying the map until Sweep removes them. This suits caches of objects that can
+// be rebuilt: a value stays cached for as long as some caller still uses it.
+type WeakCASMap[K comparable, V any] struct {
+	data *CASMap[K, weak.Pointer[V]]
+}
+
+// NewWeakCASMap creates a new WeakCASMap instance.
+func NewWeakCASMap[K comparable, V any]() *WeakCASMap[K, V] {
+	return &WeakCASMap[K, V]{
+		data: NewCASMap[K, weak.Pointer[V]](),
+	}
+}
+
+// Get retrieves the value associated with the given key.
+// Returns nil and false if the key doesn't exist or its value has been reclaimed.
+func (m *WeakCASMap[K, V]) Get(key K) (*V, bool) {
+	wp, ok := m.data.Get(key)
+	if !ok {
+		return nil, false
+	}
+	value := wp.Value()
+	return value, value != nil
+}
+
+// Set associates the given value with the given key without keeping it alive.
+// A nil value is stored as an entry that is always absent.
+func (m *WeakCASMap[K, V]) Set(key K, value *V) {
+	m.data.Set(key, weak.Make(value))
+}
+
+// Delete removes the given key from the map.
+// Has no effect if the key doesn't exist.
+func (m *WeakCASMap[K, V]) Delete(key K) {
+	m.data.Delete(key)
+}
+
+// Has checks whether the given key exists in the map and its value hasn't been reclaimed.
+func (m *WeakCASMap[K, V]) Has(key K) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Len returns the number of entries in the map, including entries whose value has been reclaimed
+// but not yet removed by Sweep.
+func (m *WeakCASMap[K, V]) Len() int {
+	return m.data.Len()
+}
+
+// Range iterates over the entries whose value is still alive.
+// Calls f for each pair, stopping iteration if f returns false.
+// Iteration is over a snapshot, so it's safe to call write methods within f.
+func (m *WeakCASMap[K, V]) Range(f func(key K, value *V) bool) {
+	m.data.Range(func(key K, wp weak.Pointer[V]) bool {
+		value := wp.Value()
+		if value == nil {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
+// Sweep removes all entries whose value has been reclaimed in a single copy-on-write update and
+// returns the number of entries removed.
+func (m *WeakCASMap[K, V]) Sweep() int {
+	return m.data.DeleteWhere(func(_ K, wp weak.Pointer[V]) bool {
+		return wp.Value() == nil
+	})
+}
@@ -0,0 +1,71 @@
+package mapx
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestWeakCASMap_BasicOperations(t *testing.T) {
+	m := NewWeakCASMap[string, int]()
+	value := new(int)
+	*value = 100
+	m.Set("key1", value)
+
+	if got, ok := m.Get("key1"); !ok || got != value {
+		t.Errorf("Expected the stored pointer, got (%v, %v)", got, ok)
+	}
+	if !m.Has("key1") || m.Len() != 1 {
+		t.Error("Expected key1 to exist")
+	}
+
+	m.Set("nil", nil)
+	if m.Has("nil") {
+		t.Error("Expected a nil value to be absent")
+	}
+
+	m.Delete("key1")
+	if m.Has("key1") {
+		t.Error("Expected key1 to be deleted")
+	}
+	runtime.KeepAlive(value)
+}
+
+func TestWeakCASMap_Reclaimed(t *testing.T) {
+	// Values are large enough to bypass the tiny allocator, which frees small objects only together
+	m := NewWeakCASMap[string, [64]byte]()
+	kept := new([64]byte)
+	m.Set("kept", kept)
+	m.Set("dropped", new([64]byte))
+
+	// The only reference to the dropped value is the weak one held by the map
+	runtime.GC()
+	runtime.GC()
+
+	if _, ok := m.Get("dropped"); ok {
+		t.Error("Expected the dropped value to be reclaimed")
+	}
+	if got, ok := m.Get("kept"); !ok || got != kept {
+		t.Error("Expected the kept value to survive")
+	}
+
+	var keys []string
+	m.Range(func(key string, _ *[64]byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 1 || keys[0] != "kept" {
+		t.Errorf("Expected Range to skip reclaimed values, got %v", keys)
+	}
+
+	// Reclaimed entries occupy the map until swept
+	if m.Len() != 2 {
+		t.Errorf("Expected length 2 before Sweep, got %d", m.Len())
+	}
+	if removed := m.Sweep(); removed != 1 {
+		t.Errorf("Expected 1 entry swept, got %d", removed)
+	}
+	if m.Len() != 1 {
+		t.Errorf("Expected length 1 after Sweep, got %d", m.Len())
+	}
+	runtime.KeepAlive(kept)
+}
@@ -0,0 +1,51 @@
+package mapx
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// writeHook holds the callback registered with OnWrite.
+type writeHook struct {
+	f atomic.Pointer[func(op string, copiedEntries int, duration time.Duration)]
+}
+
+// set registers f, replacing any previous callback. A nil f removes it.
+func (h *writeHook) set(f func(op string, copiedEntries int, duration time.Duration)) {
+	if f == nil {
+		h.f.Store(nil)
+		return
+	}
+	h.f.Store(&f)
+}
+
+// begin starts timing a write operation for the currently registered callback.
+// If none is registered, the returned writeTiming reports nothing and doesn't read the clock.
+func (h *writeHook) begin() writeTiming {
+	p := h.f.Load()
+	if p == nil {
+		return writeTiming{}
+	}
+	return writeTiming{f: *p, start: time.Now()}
+}
+
+// writeTiming measures a single write operation so that it can be reported after any lock is released.
+type writeTiming struct {
+	f      func(op string, copiedEntries int, duration time.Duration)
+	start  time.Time
+	copied int
+	stored bool
+}
+
+// store records that a copy of a map with n entries was stored.
+func (t *writeTiming) store(n int) {
+	t.copied = n
+	t.stored = true
+}
+
+// report calls the callback for op if a copy was stored.
+func (t *writeTiming) report(op string) {
+	if t.f != nil && t.stored {
+		t.f(op, t.copied, time.Since(t.start))
+	}
+}
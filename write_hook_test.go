@@ -0,0 +1,56 @@
+package mapx
+
+import (
+	"testing"
+	"time"
+)
+
+// writeTimed is implemented by the maps that support OnWrite.
+type writeTimed[K comparable, V any] interface {
+	Map[K, V]
+	OnWrite(f func(op string, copiedEntries int, duration time.Duration))
+	Merge(other map[K]V)
+}
+
+// writeReport is a single call of an OnWrite callback.
+type writeReport struct {
+	op     string
+	copied int
+}
+
+func TestOnWrite(t *testing.T) {
+	forEachImpl(t, func(t *testing.T, m writeTimed[int, int]) {
+		var reports []writeReport
+		m.OnWrite(func(op string, copiedEntries int, duration time.Duration) {
+			if duration < 0 {
+				t.Errorf("Expected a non-negative duration, got %v", duration)
+			}
+			reports = append(reports, writeReport{op, copiedEntries})
+		})
+
+		// Each write reports the size of the map it copied
+		for i := 0; i < 3; i++ {
+			m.Set(i, i)
+		}
+		m.Delete(0)
+		m.Delete(100) // missing key, nothing copied
+		m.Merge(map[int]int{10: 10, 11: 11})
+
+		expected := []writeReport{{"Set", 0}, {"Set", 1}, {"Set", 2}, {"Delete", 3}, {"Merge", 2}}
+		if len(reports) != len(expected) {
+			t.Fatalf("Expected %d reports, got %v", len(expected), reports)
+		}
+		for i := range expected {
+			if reports[i] != expected[i] {
+				t.Errorf("Report %d: expected %+v, got %+v", i, expected[i], reports[i])
+			}
+		}
+
+		// A nil callback removes the hook
+		m.OnWrite(nil)
+		m.Set(20, 20)
+		if len(reports) != len(expected) {
+			t.Errorf("Expected no reports after removing the hook, got %v", reports[len(expected):])
+		}
+	})
+}